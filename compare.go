@@ -0,0 +1,164 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"github.com/echocat/kube-secrets-exporter/kubernetes"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+type Compare struct {
+	Target      kubernetes.Environment
+	NameMapping Template
+	Format      ReportFormat
+	Output      File
+}
+
+func (instance *Compare) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("compare.").
+		EnvarNamePrefix("COMPARE_")
+
+	g.Flag("target-kubeconfig", "Defines the location of the kubeconfig of the target to compare with."+
+		" If not set the one of the source is used.").
+		PlaceHolder("<kube config file>").
+		Envar("TARGET_KUBECONFIG").
+		SetValue(&instance.Target.Kubeconfig)
+	g.Flag("target-context", "Defines context of the kubeconfig of the target to compare with.").
+		PlaceHolder("<context>").
+		Required().
+		Envar("TARGET_CONTEXT").
+		StringVar(&instance.Target.Context)
+	g.Flag("target-namespace", "Defines namespace of the target to compare with."+
+		" If not set the one of the source is used.").
+		PlaceHolder("<namespace>").
+		Envar("TARGET_NAMESPACE").
+		StringVar(&instance.Target.Namespace)
+	g.Flag("name-mapping", "Golang template which maps a secret of the source to '<namespace>/<name>' of the target;"+
+		" example: '{{.Namespace}}-prod/{{.Name}}'. Empty means same namespace and name.").
+		Envar("NAME_MAPPING").
+		SetValue(&instance.NameMapping)
+	g.Flag("format", fmt.Sprintf("Which format should be used for the report. Can be: %v", AllReportFormats.String())).
+		Default(ReportFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+	g.Flag("output", "Where to write the report to. It can be a regular file, 'stdout' or 'stderr'.").
+		Default(Stdout.String()).
+		Envar("OUTPUT").
+		SetValue(&instance.Output)
+}
+
+func (instance *Compare) Execute(exporter *KubeSecretsExporter) error {
+	if instance.Target.Kubeconfig.IsEmpty() {
+		instance.Target.Kubeconfig = exporter.Environment.Kubeconfig
+	}
+	if instance.Target.Namespace == "" {
+		instance.Target.Namespace = exporter.Environment.Namespace
+	}
+
+	sourceClient, err := exporter.Environment.NewClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	targetClient, err := instance.Target.NewClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client of target: %w", err)
+	}
+	differences, err := instance.differencesOf(exporter,
+		sourceClient.CoreV1().Secrets(exporter.Environment.Namespace),
+		targetClient.CoreV1().Secrets(instance.Target.Namespace),
+	)
+	if err != nil {
+		return err
+	}
+
+	comparison := SecretComparison{
+		Differences: differences,
+	}
+	if comparison.Source, err = exporter.Environment.ContextName(); err != nil {
+		return err
+	}
+	if comparison.Target, err = instance.Target.ContextName(); err != nil {
+		return err
+	}
+
+	if err := instance.write(exporter.Output.open, comparison); err != nil {
+		return err
+	}
+	if !comparison.Differences.IsEmpty() {
+		return ErrDriftDetected
+	}
+	return nil
+}
+
+func (instance *Compare) differencesOf(exporter *KubeSecretsExporter, sourceSecrets, targetSecrets corev1.SecretInterface) (SecretDifferences, error) {
+	source := make(map[Identifier]v1.Secret)
+	if _, err := exporter.visitSecretsOf(context.Background(), sourceSecrets, func(secret v1.Secret, _ string) error {
+		id, err := instance.mappedIdentifierOf(secret)
+		if err != nil {
+			return err
+		}
+		source[id] = secret
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	unselected := &KubeSecretsExporter{
+		Filter:   exporter.Filter,
+		PageSize: exporter.PageSize,
+	}
+	target := make(map[Identifier]v1.Secret)
+	if _, err := unselected.visitSecretsOf(context.Background(), targetSecrets, func(secret v1.Secret, _ string) error {
+		id := identifierOf(secret)
+		if _, mapped := source[id]; mapped || (instance.NameMapping.IsEmpty() && exporter.Selector.Matches(secret)) {
+			target[id] = secret
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return DiffSecrets(source, target, ValueDisplayHash), nil
+}
+
+func (instance *Compare) mappedIdentifierOf(secret v1.Secret) (Identifier, error) {
+	if instance.NameMapping.IsEmpty() {
+		return identifierOf(secret), nil
+	}
+	plain, err := instance.NameMapping.Execute(secret)
+	if err != nil {
+		return "", fmt.Errorf("cannot map name of %v: %w", identifierOf(secret), err)
+	}
+	return Identifier(plain), nil
+}
+
+func (instance *Compare) write(open outputSink, comparison SecretComparison) error {
+	return writeReport(open, instance.Output, instance.Format, func(w io.Writer) error {
+		return compareTable.write(comparison.Differences, w)
+	}, comparison)
+}
+
+type SecretComparison struct {
+	Source      string            `json:"source"`
+	Target      string            `json:"target"`
+	Differences SecretDifferences `json:"differences"`
+}
+
+var compareTable = secretDifferencesTable{
+	changeHeader: "STATUS",
+	oldHeader:    "SOURCE",
+	newHeader:    "TARGET",
+	changeName: func(change SecretChange) string {
+		switch change {
+		case SecretChangeAdded:
+			return "missing in source"
+		case SecretChangeRemoved:
+			return "missing in target"
+		default:
+			return "differs"
+		}
+	},
+}
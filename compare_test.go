@@ -0,0 +1,68 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func Test_Compare_differencesOf_looks_up_targets_by_mapped_name(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	sa, sb, sc := newTestSecret("ns1", "a", map[string]string{"k": "v"}), newTestSecret("ns1", "b", map[string]string{"k": "v"}), newTestSecret("ns2", "c", nil)
+	ta, tb, tx := newTestSecret("ns1-prod", "a", map[string]string{"k": "v"}), newTestSecret("ns1-prod", "b", map[string]string{"k": "other"}), newTestSecret("ns1-prod", "x", nil)
+	source := fake.NewSimpleClientset(&sa, &sb, &sc)
+	target := fake.NewSimpleClientset(&ta, &tb, &tx)
+
+	exporter := &KubeSecretsExporter{}
+	g.Expect(exporter.Selector.Name.Set("ns1/.*")).To(BeNil())
+	instance := &Compare{}
+	g.Expect(instance.NameMapping.Set("{{.Namespace}}-prod/{{.Name}}")).To(BeNil())
+
+	actual, err := instance.differencesOf(exporter, source.CoreV1().Secrets(""), target.CoreV1().Secrets(""))
+	g.Expect(err).To(BeNil())
+	g.Expect(actual).To(HaveLen(1))
+	g.Expect(actual[0].Secret).To(Equal(Identifier("ns1-prod/b")))
+	g.Expect(actual[0].Change).To(Equal(SecretChangeModified))
+}
+
+func Test_Compare_differencesOf_without_mapping_selects_both_sides(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	sa, sc := newTestSecret("ns1", "a", nil), newTestSecret("ns2", "c", nil)
+	tb, tc := newTestSecret("ns1", "b", nil), newTestSecret("ns2", "d", nil)
+	source := fake.NewSimpleClientset(&sa, &sc)
+	target := fake.NewSimpleClientset(&tb, &tc)
+
+	exporter := &KubeSecretsExporter{}
+	g.Expect(exporter.Selector.Name.Set("ns1/.*")).To(BeNil())
+
+	actual, err := (&Compare{}).differencesOf(exporter, source.CoreV1().Secrets(""), target.CoreV1().Secrets(""))
+	g.Expect(err).To(BeNil())
+	g.Expect(actual).To(Equal(SecretDifferences{{
+		Secret: "ns1/a",
+		Change: SecretChangeRemoved,
+	}, {
+		Secret: "ns1/b",
+		Change: SecretChangeAdded,
+	}}))
+}
+
+func Test_Compare_mappedIdentifierOf(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secret := newTestSecret("ns1", "a", nil)
+	secret.Labels = map[string]string{"env": "prod"}
+
+	instance := &Compare{}
+	g.Expect(instance.mappedIdentifierOf(secret)).To(Equal(Identifier("ns1/a")))
+
+	g.Expect(instance.NameMapping.Set(`{{.Namespace}}-{{index .Labels "env"}}/{{.Name | lower}}`)).To(BeNil())
+	g.Expect(instance.mappedIdentifierOf(secret)).To(Equal(Identifier("ns1-prod/a")))
+
+	g.Expect(instance.NameMapping.Set("{{.Foo}}")).To(BeNil())
+	_, err := instance.mappedIdentifierOf(secret)
+	g.Expect(err).To(MatchError(ContainSubstring("cannot map name of ns1/a")))
+
+	g.Expect(instance.NameMapping.Set("{{")).NotTo(BeNil())
+}
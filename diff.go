@@ -0,0 +1,131 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"text/tabwriter"
+)
+
+var ErrDriftDetected = errors.New("drift detected")
+
+type Diff struct {
+	Source SecretSource
+	Values ValueDisplay
+	Format ReportFormat
+	Output File
+}
+
+func (instance *Diff) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("diff.").
+		EnvarNamePrefix("DIFF_")
+
+	g.Flag("source", "File or directory containing previously exported secrets which should be compared with the cluster.").
+		Required().
+		Envar("SOURCE").
+		SetValue(&instance.Source)
+	g.Flag("values", fmt.Sprintf("How differing values should be displayed. Can be: %v", AllValueDisplays.String())).
+		Default(ValueDisplayHidden.String()).
+		Envar("VALUES").
+		SetValue(&instance.Values)
+	g.Flag("format", fmt.Sprintf("Which format should be used for the report. Can be: %v", AllReportFormats.String())).
+		Default(ReportFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+	g.Flag("output", "Where to write the report to. It can be a regular file, 'stdout' or 'stderr'.").
+		Default(Stdout.String()).
+		Envar("OUTPUT").
+		SetValue(&instance.Output)
+}
+
+func (instance Diff) Execute(exporter *KubeSecretsExporter) error {
+	client, err := exporter.Environment.NewClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	return instance.execute(exporter, client.CoreV1().Secrets(exporter.Environment.Namespace))
+}
+
+func (instance Diff) execute(exporter *KubeSecretsExporter, secrets corev1.SecretInterface) error {
+	exported, err := instance.Source.Load()
+	if err != nil {
+		return err
+	}
+	old := make(map[Identifier]v1.Secret, len(exported))
+	for _, secret := range exported {
+		if err := exporter.onElement(secret, func(secret v1.Secret, _ string) error {
+			old[identifierOf(secret)] = secret
+			return nil
+		}); err != nil {
+			return fmt.Errorf("cannot handle exported secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+	}
+
+	current := make(map[Identifier]v1.Secret)
+	if _, err := exporter.visitSecretsOf(context.Background(), secrets, func(secret v1.Secret, _ string) error {
+		current[identifierOf(secret)] = secret
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	differences := DiffSecrets(old, current, instance.Values)
+	if err := instance.write(exporter.Output.open, differences); err != nil {
+		return err
+	}
+	if !differences.IsEmpty() {
+		return ErrDriftDetected
+	}
+	return nil
+}
+
+func (instance Diff) write(open outputSink, differences SecretDifferences) error {
+	return writeReport(open, instance.Output, instance.Format, func(w io.Writer) error {
+		return diffTable.write(differences, w)
+	}, differences)
+}
+
+type secretDifferencesTable struct {
+	changeHeader string
+	oldHeader    string
+	newHeader    string
+	changeName   func(SecretChange) string
+}
+
+var diffTable = secretDifferencesTable{
+	changeHeader: "CHANGE",
+	oldHeader:    "OLD",
+	newHeader:    "NEW",
+	changeName:   SecretChange.String,
+}
+
+func (instance secretDifferencesTable) write(differences SecretDifferences, to io.Writer) error {
+	w := tabwriter.NewWriter(to, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "%s\tSECRET\tKEY\t%s\t%s\n", instance.changeHeader, instance.oldHeader, instance.newHeader); err != nil {
+		return err
+	}
+	for _, difference := range differences {
+		change := instance.changeName(difference.Change)
+		if difference.Change != SecretChangeModified {
+			if _, err := fmt.Fprintf(w, "%s\t%v\t\t\t\n", change, difference.Secret); err != nil {
+				return err
+			}
+			continue
+		}
+		if t := difference.Type; t != nil {
+			if _, err := fmt.Fprintf(w, "%s\t%v\t<type>\t%v\t%v\n", change, difference.Secret, t.Old, t.New); err != nil {
+				return err
+			}
+		}
+		for _, key := range difference.Keys {
+			if _, err := fmt.Fprintf(w, "%s\t%v\t%s\t%s\t%s\n", instance.changeName(key.Change), difference.Secret, key.Key, key.Old, key.New); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -1,6 +1,7 @@
 package kube_secrets_exporter
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -27,6 +28,10 @@ func (instance *File) Set(plain string) error {
 		*instance = Stdout
 		return nil
 	default:
+		if File(plain).IsS3() {
+			*instance = File(plain)
+			return nil
+		}
 		fi, err := os.Stat(plain)
 		if err != nil && !os.IsNotExist(err) {
 			return err
@@ -42,6 +47,27 @@ func (instance File) String() string {
 	return string(instance)
 }
 
+func (instance File) IsRegular() bool {
+	switch instance {
+	case "", Stdout, Stderr:
+		return false
+	default:
+		return !instance.IsS3()
+	}
+}
+
+func (instance File) Exists() (bool, error) {
+	if !instance.IsRegular() {
+		return false, nil
+	}
+	if _, err := os.Stat(instance.String()); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (instance File) Open() (io.WriteCloser, error) {
 	switch instance {
 	case "":
@@ -51,6 +77,9 @@ func (instance File) Open() (io.WriteCloser, error) {
 	case Stderr:
 		return &fileWriterWrapper{os.Stderr, false}, nil
 	default:
+		if instance.IsS3() {
+			return nil, fmt.Errorf("cannot open '%v' without s3 configuration", instance)
+		}
 		dir := filepath.Dir(instance.String())
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, fmt.Errorf("cannot ensure directory for '%v': %w", instance, err)
@@ -63,6 +92,43 @@ func (instance File) Open() (io.WriteCloser, error) {
 	}
 }
 
+func compressorFor(f File, target io.WriteCloser) io.WriteCloser {
+	if strings.HasSuffix(strings.ToLower(f.String()), ".gz") {
+		return &gzipWriter{gzip.NewWriter(target), target}
+	}
+	return target
+}
+
+type gzipWriter struct {
+	*gzip.Writer
+	target io.WriteCloser
+}
+
+func (instance *gzipWriter) Abort() {
+	abortWriter(instance.target)
+}
+
+func (instance *gzipWriter) Close() error {
+	if err := instance.Writer.Close(); err != nil {
+		_ = instance.target.Close()
+		return err
+	}
+	return instance.target.Close()
+}
+
+type abortableWriter interface {
+	io.WriteCloser
+	Abort()
+}
+
+func abortWriter(w io.WriteCloser) {
+	if aw, ok := w.(abortableWriter); ok {
+		aw.Abort()
+		return
+	}
+	_ = w.Close()
+}
+
 type noopWriter struct {
 }
 
@@ -2,9 +2,8 @@ package kube_secrets_exporter
 
 import (
 	"github.com/blaubaer/kingpin"
-	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"strings"
-	"time"
 )
 
 type Filter struct {
@@ -36,24 +35,27 @@ func (instance *Filter) RegisterFlags(fg kingpin.FlagGroup) {
 		BoolVar(&instance.RemoveKubectlHints)
 }
 
-func (instance Filter) Apply(secret *v1.Secret) error {
+func (instance Filter) Apply(object metav1.Object) error {
 	if instance.RemoveCreationTimestamp {
-		secret.ObjectMeta.CreationTimestamp.Time = time.Time{}
+		object.SetCreationTimestamp(metav1.Time{})
 	}
 	if instance.RemoveResourceVersion {
-		secret.ObjectMeta.ResourceVersion = ""
+		object.SetResourceVersion("")
 	}
 	if instance.RemoveSelfLink {
-		secret.ObjectMeta.SelfLink = ""
+		object.SetSelfLink("")
 	}
 	if instance.RemoveUid {
-		secret.ObjectMeta.UID = ""
+		object.SetUID("")
 	}
 	if instance.RemoveKubectlHints {
-		for n := range secret.Annotations {
-			if strings.HasPrefix(n, "kubectl.kubernetes.io/") {
-				delete(secret.Annotations, n)
+		if annotations := object.GetAnnotations(); annotations != nil {
+			for n := range annotations {
+				if strings.HasPrefix(n, "kubectl.kubernetes.io/") {
+					delete(annotations, n)
+				}
 			}
+			object.SetAnnotations(annotations)
 		}
 	}
 	return nil
@@ -0,0 +1,187 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Git struct {
+	Repository  string
+	Message     Template
+	AuthorName  string
+	AuthorEmail string
+	Push        bool
+	Remote      string
+}
+
+func (instance *Git) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("git.").
+		EnvarNamePrefix("GIT_")
+
+	g.Flag("repository", "Working tree of a local Git repository the output files are written into."+
+		" If set the files written or removed by the export inside of it are committed afterwards. Empty means no Git.").
+		Envar("REPOSITORY").
+		StringVar(&instance.Repository)
+	g.Flag("message", "Golang template of the commit message. Available are"+
+		" '{{.Cluster}}', '{{.Secrets}}', '{{.Files}}' and '{{.Time}}'.").
+		Default("Export of {{.Cluster}}: {{.Secrets}} secrets in {{.Files}} files").
+		Envar("MESSAGE").
+		SetValue(&instance.Message)
+	g.Flag("author-name", "Name of the author of the commits.").
+		Default("kube-secrets-exporter").
+		Envar("AUTHOR_NAME").
+		StringVar(&instance.AuthorName)
+	g.Flag("author-email", "Email of the author of the commits.").
+		Default("kube-secrets-exporter@localhost").
+		Envar("AUTHOR_EMAIL").
+		StringVar(&instance.AuthorEmail)
+	g.Flag("push", "Push the commit to the remote.").
+		Envar("PUSH").
+		BoolVar(&instance.Push)
+	g.Flag("remote", "Remote to push to.").
+		Default("origin").
+		Envar("REMOTE").
+		StringVar(&instance.Remote)
+}
+
+func (instance Git) IsEnabled() bool {
+	return instance.Repository != ""
+}
+
+type GitCommitContext struct {
+	Cluster string
+	Secrets int
+	Files   int
+	Time    time.Time
+}
+
+func (instance Git) Commit(context GitCommitContext, written []File, removed []File) (bool, error) {
+	if !instance.IsEnabled() {
+		return false, nil
+	}
+	root, err := filepath.Abs(instance.Repository)
+	if err != nil {
+		return false, fmt.Errorf("cannot resolve git repository '%s': %w", instance.Repository, err)
+	}
+	paths, err := gitPathsOf(root, written)
+	if err != nil {
+		return false, err
+	}
+	if paths, err = instance.withoutIgnored(paths); err != nil {
+		return false, err
+	}
+	if len(paths) > 0 {
+		if _, err := instance.git(append([]string{"add", "--all", "--"}, paths...)...); err != nil {
+			return false, err
+		}
+	}
+	if paths, err := gitPathsOf(root, removed); err != nil {
+		return false, err
+	} else if len(paths) > 0 {
+		if _, err := instance.git(append([]string{"rm", "--cached", "--quiet", "--ignore-unmatch", "--"}, paths...)...); err != nil {
+			return false, err
+		}
+	}
+	if changed, err := instance.hasStagedChanges(); err != nil {
+		return false, err
+	} else if !changed {
+		return false, nil
+	}
+
+	message, err := instance.Message.Execute(context)
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(message) == "" {
+		message = "Export"
+	}
+	if _, err := instance.git(
+		"-c", "user.name="+instance.AuthorName,
+		"-c", "user.email="+instance.AuthorEmail,
+		"commit", "--quiet", "--no-verify", "--message", message,
+	); err != nil {
+		return false, err
+	}
+
+	if instance.Push {
+		if _, err := instance.git("push", "--quiet", instance.Remote, "HEAD"); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func gitPathsOf(root string, files []File) ([]string, error) {
+	var result []string
+	for _, f := range files {
+		if !f.IsRegular() {
+			continue
+		}
+		rel, ok := relativeToRoot(root, f.String())
+		if !ok {
+			return nil, fmt.Errorf("cannot commit '%v' because it is outside of git repository '%s'", f, root)
+		}
+		result = append(result, rel)
+	}
+	return result, nil
+}
+
+func (instance Git) contains(f File) bool {
+	root, err := filepath.Abs(instance.Repository)
+	if err != nil || !f.IsRegular() {
+		return false
+	}
+	_, ok := relativeToRoot(root, f.String())
+	return ok
+}
+
+func (instance Git) withoutIgnored(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return paths, nil
+	}
+	cmd := exec.Command("git", append([]string{"-C", instance.Repository, "check-ignore", "--"}, paths...)...)
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 1 {
+		return paths, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot determine ignored files of git repository '%s': %w", instance.Repository, err)
+	}
+	ignored := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		ignored[line] = true
+	}
+	var result []string
+	for _, candidate := range paths {
+		if !ignored[candidate] {
+			result = append(result, candidate)
+		}
+	}
+	return result, nil
+}
+
+func (instance Git) hasStagedChanges() (bool, error) {
+	cmd := exec.Command("git", "-C", instance.Repository, "diff", "--cached", "--quiet")
+	if err := cmd.Run(); err == nil {
+		return false, nil
+	} else if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == 1 {
+		return true, nil
+	} else {
+		return false, fmt.Errorf("cannot determine changes of git repository '%s': %w", instance.Repository, err)
+	}
+}
+
+func (instance Git) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", instance.Repository}, args...)...)
+	out := new(bytes.Buffer)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s in '%s' failed: %w: %s", strings.Join(args, " "), instance.Repository, err, strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,78 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Git_Commit_commits_and_pushes_only_changes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	remote, local := filepath.Join(dir, "remote.git"), filepath.Join(dir, "local")
+	runTestGit(g, dir, "init", "--quiet", "--bare", remote)
+	runTestGit(g, dir, "clone", "--quiet", remote, local)
+	instance := Git{
+		Repository:  local,
+		AuthorName:  "test",
+		AuthorEmail: "test@localhost",
+		Push:        true,
+		Remote:      "origin",
+	}
+	g.Expect(instance.Message.Set("Export of {{.Cluster}}: {{.Secrets}} secrets")).To(BeNil())
+	ns1, foreign, state := File(filepath.Join(local, "ns1.yaml")), filepath.Join(local, "foreign.txt"), File(filepath.Join(local, "state.json"))
+	g.Expect(ioutil.WriteFile(ns1.String(), []byte("foo"), 0644)).To(BeNil())
+	g.Expect(ioutil.WriteFile(foreign, []byte("foo"), 0644)).To(BeNil())
+	g.Expect(ioutil.WriteFile(state.String(), []byte("{}"), 0644)).To(BeNil())
+	g.Expect(ioutil.WriteFile(filepath.Join(local, ".git", "info", "exclude"), []byte("state.json\n"), 0644)).To(BeNil())
+
+	committed, err := instance.Commit(GitCommitContext{Cluster: "aCluster", Secrets: 2}, []File{ns1, state, Stdout}, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(committed).To(BeTrue())
+
+	committed, err = instance.Commit(GitCommitContext{Cluster: "aCluster", Secrets: 2}, []File{ns1}, nil)
+	g.Expect(err).To(BeNil())
+	g.Expect(committed).To(BeFalse())
+
+	g.Expect(os.Remove(ns1.String())).To(BeNil())
+	committed, err = instance.Commit(GitCommitContext{Cluster: "aCluster", Secrets: 0}, nil, []File{ns1})
+	g.Expect(err).To(BeNil())
+	g.Expect(committed).To(BeTrue())
+
+	g.Expect(runTestGit(g, remote, "log", "--format=%s")).To(Equal("Export of aCluster: 0 secrets\nExport of aCluster: 2 secrets"))
+	g.Expect(runTestGit(g, remote, "log", "--format=", "--name-status")).To(Equal("D\tns1.yaml\nA\tns1.yaml"))
+}
+
+func Test_Git_Commit_rejects_files_outside_of_repository(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	local := filepath.Join(dir, "local")
+	runTestGit(g, dir, "init", "--quiet", local)
+	instance := Git{
+		Repository:  local,
+		AuthorName:  "test",
+		AuthorEmail: "test@localhost",
+	}
+	outside := File(filepath.Join(dir, "ns1.yaml"))
+	g.Expect(ioutil.WriteFile(outside.String(), []byte("foo"), 0644)).To(BeNil())
+
+	committed, err := instance.Commit(GitCommitContext{}, []File{outside}, nil)
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(ContainSubstring(outside.String()))
+	g.Expect(committed).To(BeFalse())
+}
+
+func runTestGit(g *WithT, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	g.Expect(err).To(BeNil(), string(out))
+	return strings.TrimSpace(string(out))
+}
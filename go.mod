@@ -5,9 +5,13 @@ go 1.14
 require (
 	github.com/blaubaer/kingpin v1.3.8-0.20200722135458-1af65afcfd30
 	github.com/imdario/mergo v0.3.7
+	github.com/klauspost/compress v1.11.3
 	github.com/onsi/gomega v1.7.0
 	github.com/pkg/errors v0.9.1
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	gopkg.in/yaml.v3 v3.0.1
 	k8s.io/api v0.20.0-alpha.2
 	k8s.io/apimachinery v0.20.0-alpha.2
 	k8s.io/client-go v0.20.0-alpha.2
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,192 @@
+package kube_secrets_exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type Incremental struct {
+	State File
+}
+
+func (instance *Incremental) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("incremental.").
+		EnvarNamePrefix("INCREMENTAL_")
+
+	g.Flag("state", "File where the last seen resource version of each secret is stored."+
+		" If set only groups containing changed or deleted secrets are rewritten. Empty means always full export.").
+		Envar("STATE").
+		SetValue(&instance.State)
+}
+
+func (instance Incremental) NewTracker(output Output) (*IncrementalTracker, error) {
+	result := &IncrementalTracker{
+		state:  instance.State,
+		output: output.fingerprint(),
+		current: IncrementalState{
+			Output:  output.fingerprint(),
+			Secrets: make(map[Identifier]IncrementalStateEntry),
+		},
+		dirty: make(map[File]bool),
+	}
+	if !instance.State.IsRegular() {
+		return result, nil
+	}
+	previous, err := loadIncrementalState(instance.State)
+	if err != nil {
+		return nil, err
+	}
+	result.previous = previous
+	return result, nil
+}
+
+type IncrementalState struct {
+	Output  string                               `json:"output"`
+	Secrets map[Identifier]IncrementalStateEntry `json:"secrets"`
+}
+
+type IncrementalStateEntry struct {
+	ResourceVersion string `json:"resourceVersion"`
+	File            File   `json:"file,omitempty"`
+	Files           []File `json:"files,omitempty"`
+}
+
+func (instance IncrementalStateEntry) files() []File {
+	if len(instance.Files) > 0 {
+		return instance.Files
+	}
+	if instance.File != "" {
+		return []File{instance.File}
+	}
+	return nil
+}
+
+func loadIncrementalState(f File) (*IncrementalState, error) {
+	b, err := ioutil.ReadFile(f.String())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read incremental state '%v': %w", f, err)
+	}
+	var result IncrementalState
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("cannot parse incremental state '%v': %w", f, err)
+	}
+	return &result, nil
+}
+
+type IncrementalTracker struct {
+	state    File
+	output   string
+	previous *IncrementalState
+	current  IncrementalState
+	dirty    map[File]bool
+	vanishes map[File]bool
+}
+
+func (instance *IncrementalTracker) Track(id Identifier, resourceVersion string, files ...File) {
+	entry := IncrementalStateEntry{
+		ResourceVersion: resourceVersion,
+	}
+	if len(files) == 1 {
+		entry.File = files[0]
+	} else {
+		entry.Files = files
+	}
+	instance.current.Secrets[id] = entry
+	instance.vanishes = nil
+	if instance.previous == nil {
+		return
+	}
+	previous, ok := instance.previous.Secrets[id]
+	previousFiles := make(map[File]bool)
+	for _, f := range previous.files() {
+		previousFiles[f] = true
+	}
+	for _, f := range files {
+		if !ok || resourceVersion == "" || previous.ResourceVersion != resourceVersion || !previousFiles[f] {
+			instance.dirty[f] = true
+		}
+		delete(previousFiles, f)
+	}
+	for f := range previousFiles {
+		instance.dirty[f] = true
+	}
+}
+
+func (instance *IncrementalTracker) Vanished() []File {
+	vanished := instance.vanished()
+	result := make([]File, 0, len(vanished))
+	for f := range vanished {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+func (instance *IncrementalTracker) vanished() map[File]bool {
+	if instance.vanishes != nil {
+		return instance.vanishes
+	}
+	result := make(map[File]bool)
+	instance.vanishes = result
+	if instance.previous == nil {
+		return result
+	}
+	for id, previous := range instance.previous.Secrets {
+		if _, ok := instance.current.Secrets[id]; !ok {
+			for _, f := range previous.files() {
+				result[f] = true
+			}
+		}
+	}
+	return result
+}
+
+func (instance *IncrementalTracker) IsDirty(f File) bool {
+	if instance.previous == nil || instance.previous.Output != instance.output || !f.IsRegular() {
+		return true
+	}
+	if instance.dirty[f] || instance.vanished()[f] {
+		return true
+	}
+	exists, err := f.Exists()
+	return err != nil || !exists
+}
+
+func (instance *IncrementalTracker) Save() error {
+	if !instance.state.IsRegular() {
+		return nil
+	}
+	b, err := json.MarshalIndent(instance.current, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode incremental state: %w", err)
+	}
+	dir := filepath.Dir(instance.state.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot ensure directory for '%v': %w", instance.state, err)
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(instance.state.String())+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write incremental state '%v': %w", instance.state, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("cannot write incremental state '%v': %w", instance.state, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write incremental state '%v': %w", instance.state, err)
+	}
+	if err := os.Rename(tmp.Name(), instance.state.String()); err != nil {
+		return fmt.Errorf("cannot write incremental state '%v': %w", instance.state, err)
+	}
+	return nil
+}
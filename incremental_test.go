@@ -0,0 +1,90 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_IncrementalTracker_without_previous_state_marks_everything_dirty(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	instance, err := Incremental{State: File(filepath.Join(dir, "state.json"))}.NewTracker(Output{})
+	g.Expect(err).To(BeNil())
+
+	instance.Track("ns1/a", "1", File(filepath.Join(dir, "ns1.yaml")))
+
+	g.Expect(instance.IsDirty(File(filepath.Join(dir, "ns1.yaml")))).To(BeTrue())
+}
+
+func Test_IncrementalTracker_marks_only_changed_and_vanished_groups_dirty(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	state := File(filepath.Join(dir, "state.json"))
+	ns1, ns2, ns3 := File(filepath.Join(dir, "ns1.yaml")), File(filepath.Join(dir, "ns2.yaml")), File(filepath.Join(dir, "ns3.yaml"))
+	for _, f := range []File{ns1, ns2, ns3} {
+		g.Expect(ioutil.WriteFile(f.String(), []byte{}, 0644)).To(BeNil())
+	}
+
+	first, err := Incremental{State: state}.NewTracker(Output{})
+	g.Expect(err).To(BeNil())
+	first.Track("ns1/a", "1", ns1)
+	first.Track("ns2/b", "2", ns2)
+	first.Track("ns3/c", "3", ns3)
+	g.Expect(first.Vanished()).To(BeEmpty())
+	g.Expect(first.Save()).To(BeNil())
+
+	second, err := Incremental{State: state}.NewTracker(Output{})
+	g.Expect(err).To(BeNil())
+	second.Track("ns1/a", "1", ns1)
+	second.Track("ns2/b", "22", ns2)
+
+	g.Expect(second.IsDirty(ns1)).To(BeFalse())
+	g.Expect(second.IsDirty(ns2)).To(BeTrue())
+	g.Expect(second.IsDirty(ns3)).To(BeTrue())
+	g.Expect(second.Vanished()).To(Equal([]File{ns3}))
+
+	second.Track("ns3/c", "3", ns3)
+	g.Expect(second.IsDirty(ns3)).To(BeFalse())
+	g.Expect(second.Vanished()).To(BeEmpty())
+}
+
+func Test_IncrementalTracker_tracks_secrets_written_to_multiple_groups(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	state := File(filepath.Join(dir, "state.json"))
+	all, ns1, ns2 := File(filepath.Join(dir, "all.yaml")), File(filepath.Join(dir, "ns1.yaml")), File(filepath.Join(dir, "ns2.yaml"))
+	for _, f := range []File{all, ns1, ns2} {
+		g.Expect(ioutil.WriteFile(f.String(), []byte{}, 0644)).To(BeNil())
+	}
+
+	first, err := Incremental{State: state}.NewTracker(Output{})
+	g.Expect(err).To(BeNil())
+	first.Track("ns1/a", "1", all, ns1)
+	first.Track("ns2/b", "2", all, ns2)
+	g.Expect(first.Save()).To(BeNil())
+
+	second, err := Incremental{State: state}.NewTracker(Output{})
+	g.Expect(err).To(BeNil())
+	second.Track("ns1/a", "1", all, ns1)
+
+	g.Expect(second.Vanished()).To(Equal([]File{all, ns2}))
+	g.Expect(second.IsDirty(all)).To(BeTrue())
+	g.Expect(second.IsDirty(ns1)).To(BeFalse())
+	g.Expect(second.IsDirty(ns2)).To(BeTrue())
+}
+
+func newTestDirectory(g *WithT) string {
+	dir, err := ioutil.TempDir("", "kse-test-")
+	g.Expect(err).To(BeNil())
+	return dir
+}
@@ -1,18 +1,35 @@
 package kube_secrets_exporter
 
 import (
+	"context"
 	"fmt"
 	"github.com/blaubaer/kingpin"
 	"github.com/echocat/kube-secrets-exporter/kubernetes"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
 type KubeSecretsExporter struct {
 	Environment kubernetes.Environment
+	Resource    Resource
 	Selector    Selector
 	Filter      Filter
 	Output      Output
+	Diff        Diff
+	Compare     Compare
+	Incremental Incremental
+	Watch       Watch
+	Prune       Prune
+	Snapshot    Snapshot
+	Git         Git
+	Vault       Vault
+	Usage       Usage
+	Report      Report
+	Lint        Lint
+	Validation  Validation
+	Validate    Validate
 
 	PageSize uint32
 }
@@ -22,54 +39,298 @@ func (instance *KubeSecretsExporter) RegisterCommands(fe kingpin.Cmd) {
 		Default("100").
 		Envar("PAGE_SIZE").
 		Uint32Var(&instance.PageSize)
-	fe.AddAction(func(*kingpin.ParseContext) error {
-		return instance.Export()
-	})
+	fe.Flag("resource", "Which kind of resources should be exported. Can be 'secrets', 'configmaps' or any"+
+		" resource in the form of '<group>/<version>/<resource>' (example: 'cert-manager.io/v1/certificates')."+
+		" Everything else than secrets is only supported by export.").
+		Default(ResourceSecrets.String()).
+		Envar("RESOURCE").
+		SetValue(&instance.Resource)
 	fe.RegisterFlagsOf(
 		&instance.Environment,
 		&instance.Selector,
 		&instance.Filter,
+		&instance.Output.S3,
+	)
+
+	export := fe.Command("export", "Exports the selected secrets of the cluster.").
+		Default()
+	export.RegisterFlagsOf(
+		&instance.Output,
+		&instance.Incremental,
+		&instance.Prune,
+		&instance.Snapshot,
+		&instance.Git,
+		&instance.Vault,
+		&instance.Usage,
+		&instance.Validation,
+	)
+	export.AddAction(func(*kingpin.ParseContext) error {
+		return instance.Export()
+	})
+
+	watch := fe.Command("watch", "Continuously keeps the exported files in sync with the selected secrets of the cluster.")
+	watch.RegisterFlagsOf(
 		&instance.Output,
+		&instance.Watch,
 	)
+	watch.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("watch"); err != nil {
+			return err
+		}
+		return instance.Watch.Execute(instance)
+	})
+
+	diff := fe.Command("diff", "Compares the selected secrets of the cluster with previously exported files."+
+		" Exits with non-zero if there is any drift.")
+	diff.RegisterFlagsOf(&instance.Diff)
+	diff.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("diff"); err != nil {
+			return err
+		}
+		return instance.Diff.Execute(instance)
+	})
+
+	compare := fe.Command("compare", "Compares the selected secrets of the cluster with the ones of another context."+
+		" Exits with non-zero if there is any difference.")
+	compare.RegisterFlagsOf(&instance.Compare)
+	compare.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("compare"); err != nil {
+			return err
+		}
+		return instance.Compare.Execute(instance)
+	})
+
+	report := fe.Command("report", "Creates reports about the selected secrets of the cluster.")
+	reportUnused := report.Command("unused", "Reports secrets which are not referenced by any workload, service account"+
+		" or ingress and secrets whose owners do not exist anymore.")
+	reportUnused.RegisterFlagsOf(&instance.Report.Unused)
+	reportUnused.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("report unused"); err != nil {
+			return err
+		}
+		return instance.Report.Unused.Execute(instance)
+	})
+	reportDuplicates := report.Command("duplicates", "Reports secrets and keys sharing identical values without printing them.")
+	reportDuplicates.RegisterFlagsOf(&instance.Report.Duplicates)
+	reportDuplicates.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("report duplicates"); err != nil {
+			return err
+		}
+		return instance.Report.Duplicates.Execute(instance)
+	})
+
+	lint := fe.Command("lint", "Checks the selected secrets of the cluster against hygiene and policy rules."+
+		" Exits with non-zero if there is any finding of severity error.")
+	lint.RegisterFlagsOf(&instance.Lint)
+	lint.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("lint"); err != nil {
+			return err
+		}
+		return instance.Lint.Execute(instance)
+	})
+
+	validate := fe.Command("validate", "Validates the content of the selected secrets of well-known types like"+
+		" TLS, docker config, basic-auth, ssh-auth and bootstrap tokens. Exits with non-zero if any secret is invalid.")
+	validate.RegisterFlagsOf(&instance.Validate)
+	validate.AddAction(func(*kingpin.ParseContext) error {
+		if err := instance.requireSecrets("validate"); err != nil {
+			return err
+		}
+		return instance.Validate.Execute(instance)
+	})
 }
 
+type Report struct {
+	Unused     UnusedReport
+	Duplicates DuplicatesReport
+}
+
+type SecretVisitor func(secret v1.Secret, resourceVersion string) error
+
 func (instance *KubeSecretsExporter) Export() error {
-	client, err := instance.Environment.NewClient()
+	if instance.Snapshot.IsEnabled() && !instance.Output.groupings().referencesField("Snapshot") {
+		return fmt.Errorf("--snapshot.root requires '{{.Snapshot}}' inside of --output.file or the grouping rules")
+	}
+	run, err := instance.Snapshot.Begin()
 	if err != nil {
-		return fmt.Errorf("cannot create kubernetes client: %w", err)
+		return err
 	}
+	outputRun, err := newOutputRun(&instance.Environment, run.Directory)
+	if err != nil {
+		run.Abort()
+		return err
+	}
+	vaulted, err := instance.export(outputRun)
+	if err != nil {
+		run.Abort()
+		return err
+	}
+	if err := run.Commit(); err != nil {
+		return err
+	}
+	return instance.Vault.WriteAll(outputRun, vaulted)
+}
+
+func (instance *KubeSecretsExporter) export(outputRun OutputRun) (vaulted []v1.Secret, err error) {
+	writeFiles := instance.Output.IsEnabled() || !instance.Vault.IsEnabled()
 	consumer := OutputConsumer{
 		Output: instance.Output,
+		Run:    outputRun,
+	}
+	tracker, err := instance.Incremental.NewTracker(instance.Output)
+	if err != nil {
+		return nil, err
+	}
+	usages, err := instance.Usage.Collect(context.Background(), instance)
+	if err != nil {
+		return nil, err
+	}
+	var exported []Identifier
+	secrets := 0
+	if err := instance.VisitObjects(context.Background(), &instance.Environment, func(object runtime.Object, resourceVersion string) error {
+		secret, isSecret := object.(*v1.Secret)
+		if isSecret {
+			if err := instance.Validation.Check(*secret); err != nil {
+				return err
+			}
+		}
+		if isSecret && instance.Usage.Annotate {
+			usages.Annotate(secret, instance.Usage.Annotation)
+			if resourceVersion != "" {
+				resourceVersion += ";" + secret.Annotations[instance.Usage.Annotation]
+			}
+		}
+		id := identifierOfObject(object)
+		if writeFiles {
+			files, err := consumer.Consume(object)
+			if err != nil {
+				return err
+			}
+			tracker.Track(id, resourceVersion, files...)
+		}
+		if isSecret && instance.Vault.IsEnabled() {
+			vaulted = append(vaulted, *secret)
+		}
+		if isSecret {
+			secrets++
+		}
+		exported = append(exported, id)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var files, removed []File
+	if writeFiles {
+		for _, f := range tracker.Vanished() {
+			consumer.Ensure(f)
+		}
+		if err := consumer.FinalizeWhere(tracker.IsDirty); err != nil {
+			return nil, err
+		}
+		files = consumer.Files()
+		if removed, err = instance.Prune.Apply(files); err != nil {
+			return nil, err
+		}
+	}
+	if err := instance.Usage.WriteReport(instance.Output.open, usages.Of(exported)); err != nil {
+		return nil, err
+	}
+	if writeFiles {
+		if err := tracker.Save(); err != nil {
+			return nil, err
+		}
+	}
+	if instance.Git.IsEnabled() {
+		written := files
+		sideFiles := []File{instance.Incremental.State, instance.Usage.Report}
+		if instance.Prune.IsEnabled() && !instance.Prune.DryRun {
+			sideFiles = append(sideFiles, File(instance.Prune.manifestFile()))
+		}
+		for _, f := range sideFiles {
+			if instance.Git.contains(f) {
+				written = append(written, f)
+			}
+		}
+		if _, err := instance.Git.Commit(GitCommitContext{
+			Cluster: outputRun.Cluster,
+			Secrets: secrets,
+			Files:   len(files),
+			Time:    outputRun.Time,
+		}, written, removed); err != nil {
+			return nil, err
+		}
+	}
+	return vaulted, nil
+}
+
+func (instance *KubeSecretsExporter) VisitSecrets(ctx context.Context, env *kubernetes.Environment, visitor SecretVisitor) error {
+	client, err := env.NewClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
 	}
+	_, err = instance.visitSecretsOf(ctx, client.CoreV1().Secrets(env.Namespace), visitor)
+	return err
+}
+
+func (instance *KubeSecretsExporter) visitSecretsOf(ctx context.Context, secrets corev1.SecretInterface, visitor SecretVisitor) (resourceVersion string, err error) {
 	opts := metav1.ListOptions{
 		Limit: int64(instance.PageSize),
 	}
-	secrets := client.CoreV1().Secrets(instance.Environment.Namespace)
 	for {
-		resp, err := secrets.List(opts)
+		resp, err := secrets.List(ctx, opts)
 		if err != nil {
-			return fmt.Errorf("cannot retrieve secrets from kubernetes: %w", err)
+			return "", fmt.Errorf("cannot retrieve secrets from kubernetes: %w", err)
 		}
 		for _, elem := range resp.Items {
-			if err := instance.onElement(elem, &consumer); err != nil {
-				return fmt.Errorf("cannot handle secret %s/%s: %w", elem.Namespace, elem.Name, err)
+			if err := instance.onElement(elem, visitor); err != nil {
+				return "", fmt.Errorf("cannot handle secret %s/%s: %w", elem.Namespace, elem.Name, err)
 			}
 		}
 		if v := resp.Continue; v != "" {
 			opts.Continue = v
 		} else {
-			break
+			return resp.ResourceVersion, nil
 		}
 	}
-	return consumer.Finalize()
 }
 
-func (instance *KubeSecretsExporter) onElement(secret v1.Secret, consumer *OutputConsumer) error {
+func (instance *KubeSecretsExporter) selectedSecrets(ctx context.Context) ([]v1.Secret, error) {
+	client, err := instance.Environment.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	return instance.selectedSecretsOf(ctx, client.CoreV1().Secrets(instance.Environment.Namespace))
+}
+
+func (instance *KubeSecretsExporter) selectedSecretsOf(ctx context.Context, secrets corev1.SecretInterface) ([]v1.Secret, error) {
+	selection := &KubeSecretsExporter{
+		Selector: instance.Selector,
+		PageSize: instance.PageSize,
+	}
+	var result []v1.Secret
+	if _, err := selection.visitSecretsOf(ctx, secrets, func(secret v1.Secret, _ string) error {
+		result = append(result, secret)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (instance *KubeSecretsExporter) requireSecrets(command string) error {
+	if !instance.Resource.IsSecrets() {
+		return fmt.Errorf("%s supports only secrets but resource is %v", command, instance.Resource)
+	}
+	return nil
+}
+
+func (instance *KubeSecretsExporter) onElement(secret v1.Secret, visitor SecretVisitor) error {
 	if instance.Selector.Matches(secret) {
+		resourceVersion := secret.ResourceVersion
 		if err := instance.Filter.Apply(&secret); err != nil {
 			return err
 		}
-		if err := consumer.Consume(&secret); err != nil {
+		if err := visitor(secret, resourceVersion); err != nil {
 			return err
 		}
 	}
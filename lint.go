@@ -0,0 +1,456 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+var ErrLintViolations = errors.New("lint violations found")
+
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityNote    = "note"
+)
+
+var commonPasswords = map[string]bool{
+	"password": true, "passw0rd": true, "123456": true, "12345678": true, "qwerty": true,
+	"admin": true, "root": true, "secret": true, "changeme": true, "letmein": true, "welcome": true,
+}
+
+type Lint struct {
+	Config LintConfig
+	Format LintFormat
+	Output File
+
+	now func() time.Time
+}
+
+func (instance *Lint) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("lint.").
+		EnvarNamePrefix("LINT_")
+
+	g.Flag("config", "YAML or JSON file which configures the rules. Each entry of 'rules' is the id of a rule and can"+
+		fmt.Sprintf(" define 'enabled', 'severity' and the options of the rule. Rules: %s", strings.Join(lintRuleIds(), ","))).
+		Envar("CONFIG").
+		SetValue(&instance.Config)
+	g.Flag("format", fmt.Sprintf("Which format should be used for the results. Can be: %v", AllLintFormats.String())).
+		Default(LintFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+	g.Flag("output", "Where to write the results to. It can be a regular file, 'stdout' or 'stderr'.").
+		Default(Stdout.String()).
+		Envar("OUTPUT").
+		SetValue(&instance.Output)
+}
+
+type LintRuleConfig struct {
+	Enabled    *bool  `yaml:"enabled" json:"enabled,omitempty"`
+	Severity   string `yaml:"severity" json:"severity,omitempty"`
+	Label      string `yaml:"label" json:"label,omitempty"`
+	Annotation string `yaml:"annotation" json:"annotation,omitempty"`
+	MaxAgeDays int    `yaml:"maxAgeDays" json:"maxAgeDays,omitempty"`
+	Keys       string `yaml:"keys" json:"keys,omitempty"`
+	MinLength  int    `yaml:"minLength" json:"minLength,omitempty"`
+	MaxBytes   int    `yaml:"maxBytes" json:"maxBytes,omitempty"`
+}
+
+func (instance LintRuleConfig) mergedWith(override LintRuleConfig) LintRuleConfig {
+	if override.Enabled != nil {
+		instance.Enabled = override.Enabled
+	}
+	if override.Severity != "" {
+		instance.Severity = override.Severity
+	}
+	if override.Label != "" {
+		instance.Label = override.Label
+	}
+	if override.Annotation != "" {
+		instance.Annotation = override.Annotation
+	}
+	if override.MaxAgeDays != 0 {
+		instance.MaxAgeDays = override.MaxAgeDays
+	}
+	if override.Keys != "" {
+		instance.Keys = override.Keys
+	}
+	if override.MinLength != 0 {
+		instance.MinLength = override.MinLength
+	}
+	if override.MaxBytes != 0 {
+		instance.MaxBytes = override.MaxBytes
+	}
+	return instance
+}
+
+func (instance LintRuleConfig) isEnabled() bool {
+	return instance.Enabled == nil || *instance.Enabled
+}
+
+type LintConfig struct {
+	file  string
+	rules map[string]LintRuleConfig
+}
+
+type lintConfigPayload struct {
+	Rules map[string]LintRuleConfig `yaml:"rules"`
+}
+
+func (instance *LintConfig) Set(plain string) error {
+	if strings.TrimSpace(plain) == "" {
+		*instance = LintConfig{}
+		return nil
+	}
+	content, err := ioutil.ReadFile(plain)
+	if err != nil {
+		return fmt.Errorf("cannot read lint config: %w", err)
+	}
+	rules, err := parseLintConfig(plain, content)
+	if err != nil {
+		return err
+	}
+	*instance = LintConfig{
+		file:  plain,
+		rules: rules,
+	}
+	return nil
+}
+
+func (instance LintConfig) String() string {
+	return instance.file
+}
+
+func parseLintConfig(name string, content []byte) (map[string]LintRuleConfig, error) {
+	var payload lintConfigPayload
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&payload); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("illegal lint config %s: %w", name, err)
+	}
+	for id, config := range payload.Rules {
+		if lintRuleOf(id) == nil {
+			return nil, fmt.Errorf("illegal lint config %s: unknown rule %s", name, id)
+		}
+		switch config.Severity {
+		case "", LintSeverityError, LintSeverityWarning, LintSeverityNote:
+		default:
+			return nil, fmt.Errorf("illegal lint config %s: illegal severity of rule %s: %s", name, id, config.Severity)
+		}
+		if config.Keys != "" {
+			if _, err := regexp.Compile(config.Keys); err != nil {
+				return nil, fmt.Errorf("illegal lint config %s: illegal keys of rule %s: %w", name, id, err)
+			}
+		}
+	}
+	return payload.Rules, nil
+}
+
+func (instance LintConfig) ruleConfigOf(rule *lintRule) LintRuleConfig {
+	return rule.defaults.mergedWith(instance.rules[rule.id])
+}
+
+type LintFinding struct {
+	Rule     string     `json:"rule"`
+	Severity string     `json:"severity"`
+	Secret   Identifier `json:"secret"`
+	Key      string     `json:"key,omitempty"`
+	Message  string     `json:"message"`
+}
+
+type lintViolation struct {
+	key     string
+	message string
+}
+
+type lintRule struct {
+	id          string
+	description string
+	defaults    LintRuleConfig
+	check       func(secret v1.Secret, config LintRuleConfig, now time.Time) []lintViolation
+}
+
+var lintRules = []*lintRule{{
+	id:          "missing-owner-label",
+	description: "Secrets should carry a label identifying their owner.",
+	defaults:    LintRuleConfig{Severity: LintSeverityWarning, Label: "owner"},
+	check: func(secret v1.Secret, config LintRuleConfig, _ time.Time) []lintViolation {
+		if secret.Labels[config.Label] != "" {
+			return nil
+		}
+		return []lintViolation{{message: fmt.Sprintf("label %s is missing", config.Label)}}
+	},
+}, {
+	id:          "pem-in-opaque",
+	description: "Private keys should be stored in secrets of type kubernetes.io/tls or kubernetes.io/ssh-auth.",
+	defaults:    LintRuleConfig{Severity: LintSeverityWarning},
+	check: func(secret v1.Secret, _ LintRuleConfig, _ time.Time) []lintViolation {
+		if secret.Type != "" && secret.Type != v1.SecretTypeOpaque {
+			return nil
+		}
+		var result []lintViolation
+		for _, key := range sortedKeysOf(secret) {
+			value := secretDataOf(secret)[key]
+			if bytes.Contains(value, []byte("-----BEGIN ")) && bytes.Contains(value, []byte("PRIVATE KEY-----")) {
+				result = append(result, lintViolation{key: key, message: "contains a PEM encoded private key in an Opaque secret"})
+			}
+		}
+		return result
+	},
+}, {
+	id:          "empty-value",
+	description: "Secrets should not contain empty values.",
+	defaults:    LintRuleConfig{Severity: LintSeverityWarning},
+	check: func(secret v1.Secret, _ LintRuleConfig, _ time.Time) []lintViolation {
+		var result []lintViolation
+		for _, key := range sortedKeysOf(secret) {
+			if len(secretDataOf(secret)[key]) == 0 {
+				result = append(result, lintViolation{key: key, message: "value is empty"})
+			}
+		}
+		return result
+	},
+}, {
+	id:          "rotation-overdue",
+	description: "Secrets should be rotated regularly and record the time of their last rotation.",
+	defaults:    LintRuleConfig{Severity: LintSeverityWarning, Annotation: "kube-secrets-exporter.echocat.org/rotated-at", MaxAgeDays: 90},
+	check: func(secret v1.Secret, config LintRuleConfig, now time.Time) []lintViolation {
+		maxAge := time.Duration(config.MaxAgeDays) * 24 * time.Hour
+		plain, annotated := secret.Annotations[config.Annotation]
+		if !annotated {
+			if created := secret.CreationTimestamp.Time; !created.IsZero() && now.Sub(created) > maxAge {
+				return []lintViolation{{message: fmt.Sprintf("older than %d days without annotation %s", config.MaxAgeDays, config.Annotation)}}
+			}
+			return nil
+		}
+		rotated, err := time.Parse(time.RFC3339, plain)
+		if err != nil {
+			return []lintViolation{{message: fmt.Sprintf("annotation %s is not a RFC3339 timestamp: %s", config.Annotation, plain)}}
+		}
+		if now.Sub(rotated) > maxAge {
+			return []lintViolation{{message: fmt.Sprintf("last rotated more than %d days ago at %s", config.MaxAgeDays, plain)}}
+		}
+		return nil
+	},
+}, {
+	id:          "weak-password",
+	description: "Passwords should be long, uncommon and mix different kinds of characters.",
+	defaults:    LintRuleConfig{Severity: LintSeverityError, Keys: `(?i)(pass(word)?|passwd|pwd)`, MinLength: 12},
+	check: func(secret v1.Secret, config LintRuleConfig, _ time.Time) []lintViolation {
+		keys := regexp.MustCompile(config.Keys)
+		var result []lintViolation
+		for _, key := range sortedKeysOf(secret) {
+			value := string(secretDataOf(secret)[key])
+			if !keys.MatchString(key) || value == "" {
+				continue
+			}
+			if message := weaknessOfPassword(value, config.MinLength); message != "" {
+				result = append(result, lintViolation{key: key, message: message})
+			}
+		}
+		return result
+	},
+}, {
+	id:          "oversized",
+	description: "Secrets should stay well below the size limit of Kubernetes.",
+	defaults:    LintRuleConfig{Severity: LintSeverityWarning, MaxBytes: 512 * 1024},
+	check: func(secret v1.Secret, config LintRuleConfig, _ time.Time) []lintViolation {
+		var size int
+		for key, value := range secretDataOf(secret) {
+			size += len(key) + len(value)
+		}
+		if size <= config.MaxBytes {
+			return nil
+		}
+		return []lintViolation{{message: fmt.Sprintf("contains %d bytes which exceeds the maximum of %d bytes", size, config.MaxBytes)}}
+	},
+}}
+
+func lintRuleOf(id string) *lintRule {
+	for _, rule := range lintRules {
+		if rule.id == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+func lintRuleIds() []string {
+	result := make([]string, len(lintRules))
+	for i, rule := range lintRules {
+		result[i] = rule.id
+	}
+	return result
+}
+
+func sortedKeysOf(secret v1.Secret) []string {
+	data := secretDataOf(secret)
+	result := make([]string, 0, len(data))
+	for key := range data {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func weaknessOfPassword(value string, minLength int) string {
+	if commonPasswords[strings.ToLower(value)] {
+		return "is a commonly used password"
+	}
+	if len(value) < minLength {
+		return fmt.Sprintf("is shorter than %d characters", minLength)
+	}
+	var classes int
+	for _, is := range []func(rune) bool{unicode.IsLower, unicode.IsUpper, unicode.IsDigit} {
+		if strings.IndexFunc(value, is) >= 0 {
+			classes++
+		}
+	}
+	if strings.IndexFunc(value, func(r rune) bool {
+		return !unicode.IsLower(r) && !unicode.IsUpper(r) && !unicode.IsDigit(r)
+	}) >= 0 {
+		classes++
+	}
+	if classes < 2 {
+		return "consists of only one kind of characters"
+	}
+	return ""
+}
+
+func (instance Lint) Execute(exporter *KubeSecretsExporter) error {
+	secrets, err := exporter.selectedSecrets(context.Background())
+	if err != nil {
+		return err
+	}
+	findings := instance.check(secrets)
+	if err := instance.write(exporter.Output.open, findings); err != nil {
+		return err
+	}
+	for _, finding := range findings {
+		if finding.Severity == LintSeverityError {
+			return ErrLintViolations
+		}
+	}
+	return nil
+}
+
+func (instance Lint) check(secrets []v1.Secret) []LintFinding {
+	now := time.Now()
+	if instance.now != nil {
+		now = instance.now()
+	}
+	result := []LintFinding{}
+	for _, secret := range secrets {
+		for _, rule := range lintRules {
+			config := instance.Config.ruleConfigOf(rule)
+			if !config.isEnabled() {
+				continue
+			}
+			for _, violation := range rule.check(secret, config, now) {
+				result = append(result, LintFinding{
+					Rule:     rule.id,
+					Severity: config.Severity,
+					Secret:   identifierOf(secret),
+					Key:      violation.key,
+					Message:  violation.message,
+				})
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Secret < result[j].Secret
+	})
+	return result
+}
+
+func (instance Lint) write(open outputSink, findings []LintFinding) error {
+	var write func(io.Writer) error
+	switch instance.Format {
+	case LintFormatTable:
+		write = func(w io.Writer) error {
+			return writeLintFindingsTable(findings, w)
+		}
+	case LintFormatJson:
+		write = func(w io.Writer) error {
+			return encodeReportJson(w, findings)
+		}
+	case LintFormatSarif:
+		write = func(w io.Writer) error {
+			return encodeReportJson(w, instance.sarifOf(findings))
+		}
+	default:
+		return fmt.Errorf("cannot handle lint format: %v", instance.Format)
+	}
+	return writeReportTo(open, instance.Output, "lint results", write)
+}
+
+func writeLintFindingsTable(findings []LintFinding, to io.Writer) error {
+	w := tabwriter.NewWriter(to, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SEVERITY\tRULE\tSECRET\tKEY\tMESSAGE\n"); err != nil {
+		return err
+	}
+	for _, finding := range findings {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%v\t%s\t%s\n", finding.Severity, finding.Rule, finding.Secret, finding.Key, finding.Message); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (instance Lint) sarifOf(findings []LintFinding) map[string]interface{} {
+	rules := make([]interface{}, len(lintRules))
+	for i, rule := range lintRules {
+		rules[i] = map[string]interface{}{
+			"id":               rule.id,
+			"shortDescription": map[string]interface{}{"text": rule.description},
+			"defaultConfiguration": map[string]interface{}{
+				"level":   instance.Config.ruleConfigOf(rule).Severity,
+				"enabled": instance.Config.ruleConfigOf(rule).isEnabled(),
+			},
+		}
+	}
+	results := make([]interface{}, len(findings))
+	for i, finding := range findings {
+		location := finding.Secret.String()
+		if finding.Key != "" {
+			location += "/" + finding.Key
+		}
+		results[i] = map[string]interface{}{
+			"ruleId":  finding.Rule,
+			"level":   finding.Severity,
+			"message": map[string]interface{}{"text": finding.Message},
+			"locations": []interface{}{map[string]interface{}{
+				"logicalLocations": []interface{}{map[string]interface{}{
+					"fullyQualifiedName": location,
+					"kind":               "resource",
+				}},
+			}},
+		}
+	}
+	return map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{
+				"driver": map[string]interface{}{
+					"name":           "kube-secrets-exporter",
+					"informationUri": "https://github.com/echocat/kube-secrets-exporter",
+					"rules":          rules,
+				},
+			},
+			"results": results,
+		}},
+	}
+}
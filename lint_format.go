@@ -0,0 +1,69 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LintFormat uint8
+
+const (
+	LintFormatTable = LintFormat(0)
+	LintFormatJson  = LintFormat(1)
+	LintFormatSarif = LintFormat(2)
+)
+
+func (instance *LintFormat) Set(plain string) error {
+	if v, ok := nameToLintFormat[strings.ToLower(plain)]; ok {
+		*instance = v
+		return nil
+	}
+	return fmt.Errorf("illegal lint format: %s", plain)
+}
+
+func (instance LintFormat) String() string {
+	if v, ok := lintFormatToName[instance]; ok {
+		return v
+	}
+	return fmt.Sprintf("illegal lint format: %d", instance)
+}
+
+type LintFormats []LintFormat
+
+func (instance LintFormats) String() string {
+	return strings.Join(instance.Strings(), ",")
+}
+
+func (instance LintFormats) Strings() []string {
+	strs := make([]string, len(instance))
+	for i, v := range instance {
+		strs[i] = v.String()
+	}
+	return strs
+}
+
+var (
+	lintFormatToName = map[LintFormat]string{
+		LintFormatTable: "table",
+		LintFormatJson:  "json",
+		LintFormatSarif: "sarif",
+	}
+
+	nameToLintFormat = func(in map[LintFormat]string) map[string]LintFormat {
+		result := make(map[string]LintFormat)
+		for f, n := range in {
+			result[n] = f
+		}
+		return result
+	}(lintFormatToName)
+
+	AllLintFormats = func(in map[LintFormat]string) LintFormats {
+		result := make(LintFormats, len(in))
+		var i int
+		for f := range in {
+			result[i] = f
+			i++
+		}
+		return result
+	}(lintFormatToName)
+)
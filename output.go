@@ -3,12 +3,22 @@ package kube_secrets_exporter
 import (
 	"fmt"
 	"github.com/blaubaer/kingpin"
+	"io"
 )
 
 type Output struct {
-	File     OutputGroupings
-	Format   OutputFormat
-	Bundling OutputBundling
+	File          OutputGroupings
+	Grouping      OutputGroupingConfig
+	GroupingMode  OutputGroupingMode
+	Format        OutputFormat
+	Bundling      OutputBundling
+	Archive       File
+	ArchiveFormat ArchiveFormat
+	Template      TemplateFile
+	S3            S3
+
+	ExternalSecret ExternalSecretOutput
+	Helm           HelmOutput
 }
 
 func (instance *Output) RegisterFlags(fg kingpin.FlagGroup) {
@@ -17,11 +27,24 @@ func (instance *Output) RegisterFlags(fg kingpin.FlagGroup) {
 
 	g.Flag("file", "Where to write the output to. It can be a regular file, 'stdout' or 'stderr'."+
 		"This can result in grouping of files, too by using golang template evaluation"+
-		"; example '{{.Namespace}}.yaml' will create an extra file for each element per namespace.").
-		Default(Stdout.String()).
+		"; example '{{.Namespace}}.yaml' will create an extra file for each element per namespace."+
+		" Besides the fields of the secret '{{.Cluster}}', '{{.Time}}', '{{.RunId}}' and '{{.Snapshot}}' are available"+
+		" together with the functions lower, upper, replace, trimPrefix, trimSuffix, default, label, annotation, date and safePath."+
+		" Files ending with '.gz' are gzip compressed. Locations like 's3://<bucket>/<key>' are uploaded to S3."+
+		" Empty means 'stdout', or no files at all if secrets are only written to Vault.").
 		Envar("FILE").
 		SetValue(&instance.File)
-	g.Flag("format", fmt.Sprintf("Which format should be used for output. Can be: %v", AllOutputFormats.String())).
+	g.Flag("grouping", "YAML or JSON file containing the grouping rules. If set it replaces --output.file."+
+		" Each entry of 'rules' can define 'projection', 'matcher', 'file', 'format' and 'bundling'.").
+		Envar("GROUPING").
+		SetValue(&instance.Grouping)
+	g.Flag("grouping-mode", fmt.Sprintf("Whether a secret is written to the group of the first matching rule only"+
+		" or to the groups of all matching rules. Can be: %v", AllOutputGroupingModes.String())).
+		Default(OutputGroupingModeFirst.String()).
+		Envar("GROUPING_MODE").
+		SetValue(&instance.GroupingMode)
+	g.Flag("format", fmt.Sprintf("Which format should be used for output. 'auto' derives it for each group from the"+
+		" file extension (.yaml, .yml, .json, .ndjson, .env). Can be: %v", AllOutputFormats.String())).
 		Default(OutputFormatYaml.String()).
 		Envar("FORMAT").
 		SetValue(&instance.Format)
@@ -29,4 +52,58 @@ func (instance *Output) RegisterFlags(fg kingpin.FlagGroup) {
 		Default(OutputBundlingList.String()).
 		Envar("BUNDLING").
 		SetValue(&instance.Bundling)
+	g.Flag("archive", "If set all groups are written into one archive instead of separate files, using the file names of the"+
+		" groups as paths inside of it. It can be a regular file or 'stdout'. Empty means no archive.").
+		Envar("ARCHIVE").
+		SetValue(&instance.Archive)
+	g.Flag("archive-format", fmt.Sprintf("Which format should be used for the archive. 'auto' derives it from the file extension of the archive. Can be: %v", AllArchiveFormats.String())).
+		Default(ArchiveFormatAuto.String()).
+		Envar("ARCHIVE_FORMAT").
+		SetValue(&instance.ArchiveFormat)
+	g.Flag("template", "Golang template file which renders each group if format 'template' is used."+
+		" Available are '{{.File}}' and '{{.Secrets}}' where each secret provides its decoded data as '{{.Values}}'."+
+		" Additional functions: b64enc, b64dec, toYaml, toJson, indent, sha256").
+		Envar("TEMPLATE").
+		SetValue(&instance.Template)
+	g.RegisterFlagsOf(
+		&instance.ExternalSecret,
+		&instance.Helm,
+	)
+}
+
+func (instance Output) open(f File) (io.WriteCloser, error) {
+	if f.IsS3() {
+		return instance.S3.Open(f)
+	}
+	return f.Open()
+}
+
+func (instance Output) IsEnabled() bool {
+	return len(instance.groupings()) > 0 || instance.Archive != ""
+}
+
+func (instance Output) resolvedFor(f File, explicitBundling bool) Output {
+	if instance.Format == OutputFormatAuto {
+		format, bundling := outputFormatOfExtension(f)
+		instance.Format = format
+		if bundling != nil && !explicitBundling {
+			instance.Bundling = *bundling
+		}
+	}
+	return instance
+}
+
+func (instance Output) targets(context interface{}) ([]OutputTarget, error) {
+	return instance.groupings().Targets(context, instance.GroupingMode)
+}
+
+func (instance Output) groupings() OutputGroupings {
+	if !instance.Grouping.IsEmpty() {
+		return instance.Grouping.groupings
+	}
+	return instance.File
+}
+
+func (instance Output) fingerprint() string {
+	return fmt.Sprintf("file=%v;grouping=%v;mode=%v;format=%v;bundling=%v;archive=%v;template=%v;s3=%v", instance.File, instance.Grouping.fingerprint(), instance.GroupingMode, instance.Format, instance.Bundling, instance.Archive, instance.Template, instance.S3.fingerprint())
 }
@@ -0,0 +1,228 @@
+package kube_secrets_exporter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ArchiveFormat uint8
+
+const (
+	ArchiveFormatAuto   = ArchiveFormat(0)
+	ArchiveFormatTarGz  = ArchiveFormat(1)
+	ArchiveFormatZip    = ArchiveFormat(2)
+	ArchiveFormatTarZst = ArchiveFormat(3)
+)
+
+func (instance *ArchiveFormat) Set(plain string) error {
+	if v, ok := nameToArchiveFormat[strings.ToLower(plain)]; ok {
+		*instance = v
+		return nil
+	}
+	return fmt.Errorf("illegal archive format: %s", plain)
+}
+
+func (instance ArchiveFormat) String() string {
+	if v, ok := archiveFormatToName[instance]; ok {
+		return v
+	}
+	return fmt.Sprintf("illegal archive format: %d", instance)
+}
+
+func (instance ArchiveFormat) resolveFor(f File) (ArchiveFormat, error) {
+	if instance != ArchiveFormatAuto {
+		return instance, nil
+	}
+	name := strings.ToLower(f.String())
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveFormatTarGz, nil
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveFormatZip, nil
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return ArchiveFormatTarZst, nil
+	default:
+		return 0, fmt.Errorf("cannot determine archive format of '%v', please specify it explicitly", f)
+	}
+}
+
+func (instance ArchiveFormat) newWriter(w io.Writer) (archiveWriter, error) {
+	switch instance {
+	case ArchiveFormatTarGz:
+		gw := gzip.NewWriter(w)
+		return &tarArchiveWriter{tar.NewWriter(gw), gw}, nil
+	case ArchiveFormatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchiveWriter{tar.NewWriter(zw), zw}, nil
+	case ArchiveFormatZip:
+		return &zipArchiveWriter{zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("cannot handle archive format: %v", instance)
+	}
+}
+
+type ArchiveFormats []ArchiveFormat
+
+func (instance ArchiveFormats) String() string {
+	return strings.Join(instance.Strings(), ",")
+}
+
+func (instance ArchiveFormats) Strings() []string {
+	strs := make([]string, len(instance))
+	for i, v := range instance {
+		strs[i] = v.String()
+	}
+	return strs
+}
+
+var (
+	archiveFormatToName = map[ArchiveFormat]string{
+		ArchiveFormatAuto:   "auto",
+		ArchiveFormatTarGz:  "tar.gz",
+		ArchiveFormatZip:    "zip",
+		ArchiveFormatTarZst: "tar.zst",
+	}
+
+	nameToArchiveFormat = func(in map[ArchiveFormat]string) map[string]ArchiveFormat {
+		result := make(map[string]ArchiveFormat)
+		for f, n := range in {
+			result[n] = f
+		}
+		return result
+	}(archiveFormatToName)
+
+	AllArchiveFormats = func(in map[ArchiveFormat]string) ArchiveFormats {
+		result := make(ArchiveFormats, len(in))
+		var i int
+		for f := range in {
+			result[i] = f
+			i++
+		}
+		return result
+	}(archiveFormatToName)
+)
+
+type archiveWriter interface {
+	add(name string, content []byte, modified time.Time) error
+	Close() error
+}
+
+func archivePathOf(f File, format OutputFormat, root string) string {
+	if f.IsS3() {
+		return strings.TrimPrefix(path.Clean("/"+f.String()[len(s3Scheme):]), "/")
+	}
+	if !f.IsRegular() {
+		return f.String() + "." + format.Extension()
+	}
+	if root != "" {
+		if rel, ok := relativeToRoot(root, f.String()); ok {
+			return rel
+		}
+	}
+	result := path.Clean("/" + strings.ReplaceAll(f.String(), "\\", "/"))
+	return strings.TrimPrefix(result, "/")
+}
+
+func (instance Output) archiveRoot() string {
+	var result string
+	for _, grouping := range instance.groupings() {
+		dir := grouping.staticDirectory()
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		if result == "" {
+			result = dir
+		} else {
+			result = commonDirectoryOf(result, dir)
+		}
+	}
+	return result
+}
+
+func commonDirectoryOf(a, b string) string {
+	as, bs := strings.Split(a, string(filepath.Separator)), strings.Split(b, string(filepath.Separator))
+	var i int
+	for i < len(as) && i < len(bs) && as[i] == bs[i] {
+		i++
+	}
+	if result := strings.Join(as[:i], string(filepath.Separator)); result != "" {
+		return result
+	}
+	return string(filepath.Separator)
+}
+
+type archiveEntryWriter struct {
+	bytes.Buffer
+	archive  archiveWriter
+	name     string
+	modified time.Time
+	closed   bool
+}
+
+func (instance *archiveEntryWriter) Abort() {
+	instance.closed = true
+}
+
+func (instance *archiveEntryWriter) Close() error {
+	if instance.closed {
+		return nil
+	}
+	instance.closed = true
+	return instance.archive.add(instance.name, instance.Bytes(), instance.modified)
+}
+
+type tarArchiveWriter struct {
+	*tar.Writer
+	compressor io.WriteCloser
+}
+
+func (instance *tarArchiveWriter) add(name string, content []byte, modified time.Time) error {
+	if err := instance.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(content)),
+		Mode:     0644,
+		ModTime:  modified,
+	}); err != nil {
+		return err
+	}
+	_, err := instance.Write(content)
+	return err
+}
+
+func (instance *tarArchiveWriter) Close() error {
+	if err := instance.Writer.Close(); err != nil {
+		_ = instance.compressor.Close()
+		return err
+	}
+	return instance.compressor.Close()
+}
+
+type zipArchiveWriter struct {
+	*zip.Writer
+}
+
+func (instance *zipArchiveWriter) add(name string, content []byte, modified time.Time) error {
+	w, err := instance.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
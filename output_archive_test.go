@@ -0,0 +1,109 @@
+package kube_secrets_exporter
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OutputConsumer_with_tarGz_archive_contains_all_groups(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	archive := filepath.Join(dir, "out.tar.gz")
+	instance := newTestArchivingOutputConsumer(g, archive)
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	f, err := os.Open(archive)
+	g.Expect(err).To(BeNil())
+	defer func() { _ = f.Close() }()
+	gr, err := gzip.NewReader(f)
+	g.Expect(err).To(BeNil())
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		g.Expect(err).To(BeNil())
+		names = append(names, h.Name)
+	}
+	g.Expect(names).To(Equal([]string{"secrets/ns1.yaml", "secrets/ns2.yaml.gz"}))
+}
+
+func Test_OutputConsumer_with_zip_archive_contains_all_groups(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	archive := filepath.Join(dir, "out.zip")
+	instance := newTestArchivingOutputConsumer(g, archive)
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	zr, err := zip.OpenReader(archive)
+	g.Expect(err).To(BeNil())
+	defer func() { _ = zr.Close() }()
+	g.Expect(zr.File).To(HaveLen(2))
+	g.Expect(zr.File[0].Name).To(Equal("secrets/ns1.yaml"))
+	r, err := zr.File[0].Open()
+	g.Expect(err).To(BeNil())
+	content, err := ioutil.ReadAll(r)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(content)).To(ContainSubstring("name: a"))
+}
+
+func Test_OutputConsumer_with_archive_uses_paths_relative_to_static_prefix(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	archive := filepath.Join(dir, "out.zip")
+	instance := &OutputConsumer{}
+	g.Expect(instance.File.Set(filepath.Join(dir, "backups", "{{.Namespace}}", "secrets.yaml"))).To(BeNil())
+	g.Expect(instance.Archive.Set(archive)).To(BeNil())
+	a, b := newTestSecret("ns1", "a", nil), newTestSecret("ns2", "b", nil)
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	zr, err := zip.OpenReader(archive)
+	g.Expect(err).To(BeNil())
+	defer func() { _ = zr.Close() }()
+	g.Expect(zr.File).To(HaveLen(2))
+	g.Expect(zr.File[0].Name).To(Equal("ns1/secrets.yaml"))
+	g.Expect(zr.File[1].Name).To(Equal("ns2/secrets.yaml"))
+}
+
+func newTestArchivingOutputConsumer(g *WithT, archive string) *OutputConsumer {
+	result := &OutputConsumer{}
+	g.Expect(result.File.Set(`{{if eq .Namespace "ns1"}}secrets/ns1.yaml{{else}}secrets/ns2.yaml.gz{{end}}`)).To(BeNil())
+	g.Expect(result.Archive.Set(archive)).To(BeNil())
+	a, b := newTestSecret("ns1", "a", nil), newTestSecret("ns2", "b", nil)
+	_, err := result.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = result.Consume(&b)
+	g.Expect(err).To(BeNil())
+	return result
+}
+
+func Test_archivePathOf_of_non_regular_files_is_unique(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(archivePathOf("s3://bucket/ns1/secrets.yaml", OutputFormatYaml, "")).To(Equal("bucket/ns1/secrets.yaml"))
+	g.Expect(archivePathOf("s3://bucket/ns2/secrets.yaml", OutputFormatYaml, "")).To(Equal("bucket/ns2/secrets.yaml"))
+	g.Expect(archivePathOf(Stdout, OutputFormatYaml, "")).To(Equal("stdout.yaml"))
+	g.Expect(archivePathOf(Stderr, OutputFormatJson, "")).To(Equal("stderr.json"))
+}
@@ -0,0 +1,63 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (instance *OutputConsumer) writeGroupAsEnv(f File, values []runtime.Object, w io.Writer) error {
+	result := new(strings.Builder)
+	written := make(map[string]string)
+	for i, value := range values {
+		secret, ok := value.(*v1.Secret)
+		if !ok {
+			return fmt.Errorf("cannot write %v as env file", value.GetObjectKind().GroupVersionKind().Kind)
+		}
+		id := secret.Namespace + "/" + secret.Name
+
+		data := secretDataOf(*secret)
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if i > 0 {
+			result.WriteString("\n")
+		}
+		result.WriteString("# " + id + "\n")
+		for _, key := range keys {
+			if !envKeyPattern.MatchString(key) {
+				return fmt.Errorf("cannot write key %s of secret %s to env file %v: illegal variable name", key, id, f)
+			}
+			if !utf8.Valid(data[key]) {
+				return fmt.Errorf("cannot write binary value of key %s of secret %s to env file %v", key, id, f)
+			}
+			if other, exists := written[key]; exists {
+				return fmt.Errorf("cannot write key %s of secret %s to env file %v: already written by secret %s", key, id, f, other)
+			}
+			written[key] = id
+			result.WriteString(key + "=" + quoteEnvValue(string(data[key])) + "\n")
+		}
+	}
+	if _, err := io.WriteString(w, result.String()); err != nil {
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	return nil
+}
+
+func quoteEnvValue(plain string) string {
+	if plain != "" && !strings.ContainsAny(plain, " \t\r\n\"'\\$#`") {
+		return plain
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, `$`, `\$`, "`", "\\`")
+	return `"` + replacer.Replace(plain) + `"`
+}
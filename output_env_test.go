@@ -0,0 +1,66 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OutputConsumer_with_env_format_writes_variables(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestSecret("ns1", "a", map[string]string{"USER": "foo", "PASSWORD": "b\"a r\n"})
+	b := newTestSecret("ns1", "b", map[string]string{"TOKEN": "$x"})
+
+	buf := new(bytes.Buffer)
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatEnv
+	g.Expect(instance.encodeGroup("ns1.env", []runtime.Object{&a, &b}, buf)).To(BeNil())
+
+	g.Expect(buf.String()).To(Equal(`# ns1/a
+PASSWORD="b\"a r\n"
+USER=foo
+
+# ns1/b
+TOKEN="\$x"
+`))
+}
+
+func Test_OutputConsumer_with_env_format_fails_on_duplicate_keys(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestSecret("ns1", "a", map[string]string{"USER": "foo"})
+	b := newTestSecret("ns1", "b", map[string]string{"USER": "bar"})
+
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatEnv
+	err := instance.encodeGroup("ns1.env", []runtime.Object{&a, &b}, new(bytes.Buffer))
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(ContainSubstring("already written by secret ns1/a"))
+}
+
+func Test_OutputConsumer_with_auto_format_infers_format_of_each_group(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "a", map[string]string{"FOO": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatAuto
+	for _, file := range []string{"{{.Namespace}}.json", "all.yaml", "all.ndjson", "all.env.gz"} {
+		g.Expect(instance.File.Set(filepath.Join(dir, file))).To(BeNil())
+		_, err := instance.Consume(&a)
+		g.Expect(err).To(BeNil())
+	}
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1.json"))).To(HavePrefix("{\n"))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "all.yaml"))).To(HavePrefix("apiVersion: v1\n"))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "all.ndjson"))).To(HavePrefix(`{"metadata":{"name":"a"`))
+	g.Expect(instance.outputOf(File(filepath.Join(dir, "all.env.gz"))).Format).To(Equal(OutputFormatEnv))
+}
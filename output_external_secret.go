@@ -0,0 +1,144 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sort"
+)
+
+type ExternalSecretOutput struct {
+	ApiVersion      string
+	StoreName       string
+	StoreKind       string
+	RefreshInterval string
+	RemoteKey       Template
+	RemoteProperty  Template
+}
+
+func (instance *ExternalSecretOutput) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("external-secret.").
+		EnvarNamePrefix("EXTERNAL_SECRET_")
+
+	g.Flag("api-version", "API version of the generated ExternalSecret resources.").
+		Default("external-secrets.io/v1beta1").
+		Envar("API_VERSION").
+		StringVar(&instance.ApiVersion)
+	g.Flag("store-name", "Name of the SecretStore or ClusterSecretStore the generated ExternalSecret resources refer to.").
+		Envar("STORE_NAME").
+		StringVar(&instance.StoreName)
+	g.Flag("store-kind", "Kind of the referenced store. Can be: SecretStore,ClusterSecretStore").
+		Default("SecretStore").
+		Envar("STORE_KIND").
+		EnumVar(&instance.StoreKind, "SecretStore", "ClusterSecretStore")
+	g.Flag("refresh-interval", "How often the generated ExternalSecret resources are refreshed.").
+		Default("1h").
+		Envar("REFRESH_INTERVAL").
+		StringVar(&instance.RefreshInterval)
+	g.Flag("remote-key", "Golang template of the key inside of the store for each key of a secret."+
+		" Available are all fields of the secret and '{{.Key}}'.").
+		Default("{{.Namespace}}/{{.Name}}").
+		Envar("REMOTE_KEY").
+		SetValue(&instance.RemoteKey)
+	g.Flag("remote-property", "Golang template of the property inside of the remote key for each key of a secret."+
+		" Empty means no property.").
+		Default("{{.Key}}").
+		Envar("REMOTE_PROPERTY").
+		SetValue(&instance.RemoteProperty)
+}
+
+type ExternalSecretKeyContext struct {
+	*v1.Secret
+	Key string
+}
+
+func (instance ExternalSecretOutput) convert(object runtime.Object) (runtime.Object, error) {
+	secret, ok := object.(*v1.Secret)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %v into an ExternalSecret", object.GetObjectKind().GroupVersionKind().Kind)
+	}
+	if instance.StoreName == "" {
+		return nil, fmt.Errorf("store name of ExternalSecret is required")
+	}
+
+	keys := make([]string, 0, len(secret.Data)+len(secret.StringData))
+	for key := range secretDataOf(*secret) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	data := make([]interface{}, len(keys))
+	for i, key := range keys {
+		context := ExternalSecretKeyContext{Secret: secret, Key: key}
+		remoteKey, err := instance.RemoteKey.Execute(context)
+		if err != nil {
+			return nil, err
+		}
+		remoteRef := map[string]interface{}{
+			"key": remoteKey,
+		}
+		if property, err := instance.RemoteProperty.Execute(context); err != nil {
+			return nil, err
+		} else if property != "" {
+			remoteRef["property"] = property
+		}
+		data[i] = map[string]interface{}{
+			"secretKey": key,
+			"remoteRef": remoteRef,
+		}
+	}
+
+	templateMetadata := make(map[string]interface{})
+	if v := secret.Labels; len(v) > 0 {
+		templateMetadata["labels"] = stringMapToInterfaceMap(v)
+	}
+	if v := secret.Annotations; len(v) > 0 {
+		templateMetadata["annotations"] = stringMapToInterfaceMap(v)
+	}
+	target := map[string]interface{}{
+		"name":           secret.Name,
+		"creationPolicy": "Owner",
+	}
+	targetTemplate := make(map[string]interface{})
+	if secret.Type != "" {
+		targetTemplate["type"] = string(secret.Type)
+	}
+	if len(templateMetadata) > 0 {
+		targetTemplate["metadata"] = templateMetadata
+	}
+	if len(targetTemplate) > 0 {
+		target["template"] = targetTemplate
+	}
+
+	metadata := map[string]interface{}{
+		"name": secret.Name,
+	}
+	if secret.Namespace != "" {
+		metadata["namespace"] = secret.Namespace
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": instance.ApiVersion,
+		"kind":       "ExternalSecret",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"refreshInterval": instance.RefreshInterval,
+			"secretStoreRef": map[string]interface{}{
+				"name": instance.StoreName,
+				"kind": instance.StoreKind,
+			},
+			"target": target,
+			"data":   data,
+		},
+	}}, nil
+}
+
+func stringMapToInterfaceMap(in map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		result[k] = v
+	}
+	return result
+}
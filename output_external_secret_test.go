@@ -0,0 +1,59 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	"testing"
+)
+
+func Test_OutputConsumer_with_externalSecret_format_writes_ExternalSecrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secret := newTestSecret("ns1", "a", map[string]string{"password": "bar", "user": "foo"})
+	secret.Labels = map[string]string{"team": "a"}
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatExternalSecret
+	instance.Bundling = OutputBundlingSeparation
+	instance.ExternalSecret = ExternalSecretOutput{
+		ApiVersion:      "external-secrets.io/v1beta1",
+		StoreName:       "aStore",
+		StoreKind:       "ClusterSecretStore",
+		RefreshInterval: "1h",
+	}
+	g.Expect(instance.ExternalSecret.RemoteKey.Set("{{.Namespace}}/{{.Name}}")).To(BeNil())
+	g.Expect(instance.ExternalSecret.RemoteProperty.Set("{{.Key}}")).To(BeNil())
+	buf := new(bytes.Buffer)
+
+	err := instance.encodeGroup(Stdout, []runtime.Object{&secret}, buf)
+
+	g.Expect(err).To(BeNil())
+	g.Expect(buf.String()).To(Equal(`apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: a
+  namespace: ns1
+spec:
+  data:
+  - remoteRef:
+      key: ns1/a
+      property: password
+    secretKey: password
+  - remoteRef:
+      key: ns1/a
+      property: user
+    secretKey: user
+  refreshInterval: 1h
+  secretStoreRef:
+    kind: ClusterSecretStore
+    name: aStore
+  target:
+    creationPolicy: Owner
+    name: a
+    template:
+      metadata:
+        labels:
+          team: a
+      type: Opaque
+`))
+}
@@ -8,8 +8,16 @@ import (
 type OutputFormat uint8
 
 const (
-	OutputFormatYaml = OutputFormat(0)
-	OutputFormatJson = OutputFormat(1)
+	OutputFormatYaml           = OutputFormat(0)
+	OutputFormatJson           = OutputFormat(1)
+	OutputFormatExternalSecret = OutputFormat(2)
+	OutputFormatKustomize      = OutputFormat(3)
+	OutputFormatTerraform      = OutputFormat(4)
+	OutputFormatTerraformJson  = OutputFormat(5)
+	OutputFormatHelm           = OutputFormat(6)
+	OutputFormatTemplate       = OutputFormat(7)
+	OutputFormatEnv            = OutputFormat(8)
+	OutputFormatAuto           = OutputFormat(9)
 )
 
 func (instance *OutputFormat) Set(plain string) error {
@@ -27,6 +35,38 @@ func (instance OutputFormat) String() string {
 	return fmt.Sprintf("illegal output format: %d", instance)
 }
 
+func (instance OutputFormat) Extension() string {
+	switch instance {
+	case OutputFormatJson:
+		return "json"
+	case OutputFormatTerraform:
+		return "tf"
+	case OutputFormatTerraformJson:
+		return "tf.json"
+	case OutputFormatTemplate:
+		return "txt"
+	case OutputFormatEnv:
+		return "env"
+	default:
+		return "yaml"
+	}
+}
+
+func outputFormatOfExtension(f File) (OutputFormat, *OutputBundling) {
+	plain := strings.TrimSuffix(strings.ToLower(f.String()), ".gz")
+	switch {
+	case strings.HasSuffix(plain, ".json"):
+		return OutputFormatJson, nil
+	case strings.HasSuffix(plain, ".ndjson"):
+		bundling := OutputBundlingSeparation
+		return OutputFormatJson, &bundling
+	case strings.HasSuffix(plain, ".env"):
+		return OutputFormatEnv, nil
+	default:
+		return OutputFormatYaml, nil
+	}
+}
+
 type OutputFormats []OutputFormat
 
 func (instance OutputFormats) String() string {
@@ -43,8 +83,16 @@ func (instance OutputFormats) Strings() []string {
 
 var (
 	outputFormatToName = map[OutputFormat]string{
-		OutputFormatYaml: "yaml",
-		OutputFormatJson: "json",
+		OutputFormatYaml:           "yaml",
+		OutputFormatJson:           "json",
+		OutputFormatExternalSecret: "externalsecret",
+		OutputFormatKustomize:      "kustomize",
+		OutputFormatTerraform:      "terraform",
+		OutputFormatTerraformJson:  "terraform-json",
+		OutputFormatHelm:           "helm",
+		OutputFormatTemplate:       "template",
+		OutputFormatEnv:            "env",
+		OutputFormatAuto:           "auto",
 	}
 
 	nameToOutputFormat = func(in map[OutputFormat]string) map[string]OutputFormat {
@@ -2,19 +2,28 @@ package kube_secrets_exporter
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"github.com/echocat/kube-secrets-exporter/kubernetes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
+	"time"
 )
 
 type OutputGrouping struct {
-	projection      *template.Template
+	projection      *scopedTemplate
 	plainProjection string
 	matcher         *regexp.Regexp
 	plainMatcher    string
-	file            *template.Template
+	file            *scopedTemplate
 	plainFile       string
+	format          *OutputFormat
+	bundling        *OutputBundling
 }
 
 func (instance *OutputGrouping) Set(plain string) (err error) {
@@ -27,34 +36,40 @@ func (instance *OutputGrouping) Set(plain string) (err error) {
 
 	var result OutputGrouping
 	if len(parts) == 3 {
-		result.plainProjection = parts[0]
-		result.plainMatcher = parts[1]
-		result.plainFile = parts[2]
+		result, err = newOutputGrouping(parts[0], parts[1], parts[2])
 	} else if len(parts) == 1 {
-		result.plainProjection = ""
-		result.plainMatcher = ".*"
-		result.plainFile = parts[0]
+		result, err = newOutputGrouping("", ".*", parts[0])
 	} else {
 		return fmt.Errorf("illegal output grouping, only 1 or 3 segmets are allowed")
 	}
+	if err != nil {
+		return err
+	}
+
+	*instance = result
+	return nil
+}
+
+func newOutputGrouping(projection, matcher, file string) (result OutputGrouping, err error) {
+	result.plainProjection = projection
+	result.plainMatcher = matcher
+	result.plainFile = file
 
-	result.projection, err = parseOutputGroupingTemplate("projection", result.plainProjection)
+	result.projection, err = parseTemplate("projection", result.plainProjection)
 	if err != nil {
-		return fmt.Errorf("illegal projection of output grouping: %w", err)
+		return OutputGrouping{}, fmt.Errorf("illegal projection of output grouping: %w", err)
 	}
 
 	result.matcher, err = regexp.Compile(result.plainMatcher)
 	if err != nil {
-		return fmt.Errorf("illegal matcher of output grouping: %w", err)
+		return OutputGrouping{}, fmt.Errorf("illegal matcher of output grouping: %w", err)
 	}
 
-	result.file, err = parseOutputGroupingTemplate("file", result.plainFile)
+	result.file, err = parseTemplate("file", result.plainFile)
 	if err != nil {
-		return fmt.Errorf("illegal file of output grouping: %w", err)
+		return OutputGrouping{}, fmt.Errorf("illegal file of output grouping: %w", err)
 	}
-
-	*instance = result
-	return nil
+	return result, nil
 }
 
 func (instance OutputGrouping) String() string {
@@ -70,7 +85,7 @@ func (instance OutputGrouping) Project(context interface{}) (*string, error) {
 		return nil, nil
 	}
 	buf := new(bytes.Buffer)
-	if err := t.Execute(buf, context); err != nil {
+	if err := executeTemplate(t, buf, context); err != nil {
 		return nil, fmt.Errorf("cannot project %v using %v: %w", context, t, err)
 	}
 	projected := buf.String()
@@ -83,7 +98,7 @@ func (instance OutputGrouping) fileNamePatternFor(context interface{}) (string,
 		return Stdout.String(), nil
 	}
 	buf := new(bytes.Buffer)
-	if err := t.Execute(buf, context); err != nil {
+	if err := executeTemplate(t, buf, context); err != nil {
 		return "", fmt.Errorf("cannot evaluate file name for %v using %v: %w", context, t, err)
 	}
 	return buf.String(), nil
@@ -116,6 +131,24 @@ func (instance OutputGrouping) Apply(context interface{}) (File, bool, error) {
 	return f, true, nil
 }
 
+func (instance OutputGrouping) staticDirectory() string {
+	plain := instance.plainFile
+	if i := strings.IndexAny(plain, "{$"); i >= 0 {
+		plain = plain[:i]
+	}
+	if plain == "" {
+		return ""
+	}
+	if strings.HasSuffix(plain, "/") || strings.HasSuffix(plain, string(filepath.Separator)) {
+		return filepath.Clean(plain)
+	}
+	return filepath.Dir(plain)
+}
+
+func (instance OutputGrouping) referencesField(name string) bool {
+	return templateReferencesField(instance.projection, name) || templateReferencesField(instance.file, name)
+}
+
 type OutputGroupings []OutputGrouping
 
 func (instance *OutputGroupings) Set(plain string) error {
@@ -143,17 +176,137 @@ func (instance OutputGroupings) Strings() []string {
 	return strs
 }
 
+func (instance OutputGroupings) referencesField(name string) bool {
+	for _, candidate := range instance {
+		if candidate.referencesField(name) {
+			return true
+		}
+	}
+	return false
+}
+
 func (instance OutputGroupings) Apply(context interface{}) (File, error) {
+	target, err := instance.Target(context)
+	return target.File, err
+}
+
+type OutputTarget struct {
+	File     File
+	Format   *OutputFormat
+	Bundling *OutputBundling
+}
+
+func (instance OutputGroupings) Target(context interface{}) (OutputTarget, error) {
+	targets, err := instance.Targets(context, OutputGroupingModeFirst)
+	if err != nil {
+		return OutputTarget{}, err
+	}
+	return targets[0], nil
+}
+
+func (instance OutputGroupings) Targets(context interface{}, mode OutputGroupingMode) ([]OutputTarget, error) {
+	var result []OutputTarget
+	seen := make(map[File]bool)
 	for _, candidate := range instance {
 		if match, ok, err := candidate.Apply(context); err != nil {
-			return "", err
-		} else if ok {
-			return match, nil
+			return nil, err
+		} else if ok && !seen[match] {
+			seen[match] = true
+			result = append(result, OutputTarget{
+				File:     match,
+				Format:   candidate.format,
+				Bundling: candidate.bundling,
+			})
+			if mode != OutputGroupingModeAll {
+				break
+			}
+		}
+	}
+	if len(result) == 0 {
+		return []OutputTarget{{File: Stdout}}, nil
+	}
+	return result, nil
+}
+
+type OutputRun struct {
+	Id       string
+	Cluster  string
+	Time     time.Time
+	Snapshot string
+}
+
+func newOutputRun(env *kubernetes.Environment, snapshot string) (OutputRun, error) {
+	cluster, err := env.ContextName()
+	if err != nil {
+		return OutputRun{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return OutputRun{}, fmt.Errorf("cannot generate run id: %w", err)
+	}
+	return OutputRun{
+		Id:       hex.EncodeToString(id),
+		Cluster:  cluster,
+		Time:     time.Now().UTC(),
+		Snapshot: snapshot,
+	}, nil
+}
+
+type OutputGroupingContext struct {
+	*v1.Secret
+	Object      runtime.Object
+	Kind        string
+	Namespace   string
+	Name        string
+	Type        string
+	Labels      map[string]string
+	Annotations map[string]string
+	Cluster     string
+	Time        time.Time
+	RunId       string
+	Snapshot    string
+}
+
+func newOutputGroupingContext(object runtime.Object, run OutputRun) interface{} {
+	snapshot := run.Snapshot
+	if snapshot == "" {
+		snapshot = "."
+	}
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return object
+	}
+	result := OutputGroupingContext{
+		Object:      object,
+		Kind:        object.GetObjectKind().GroupVersionKind().Kind,
+		Namespace:   accessor.GetNamespace(),
+		Name:        accessor.GetName(),
+		Labels:      accessor.GetLabels(),
+		Annotations: accessor.GetAnnotations(),
+		Cluster:     run.Cluster,
+		Time:        run.Time,
+		RunId:       run.Id,
+		Snapshot:    snapshot,
+	}
+	switch v := object.(type) {
+	case *v1.Secret:
+		result.Secret = v
+		result.Type = string(v.Type)
+		if result.Kind == "" {
+			result.Kind = "Secret"
+		}
+	case *v1.ConfigMap:
+		if result.Kind == "" {
+			result.Kind = "ConfigMap"
 		}
 	}
-	return Stdout, nil
+	return result
+}
+
+func (instance OutputGroupingContext) templateLabel(name string) string {
+	return instance.Labels[name]
 }
 
-func parseOutputGroupingTemplate(name, pattern string) (*template.Template, error) {
-	return template.New(name).Parse(pattern)
+func (instance OutputGroupingContext) templateAnnotation(name string) string {
+	return instance.Annotations[name]
 }
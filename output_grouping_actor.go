@@ -2,47 +2,135 @@ package kube_secrets_exporter
 
 import (
 	"fmt"
+	"io"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/client-go/kubernetes/scheme"
+	"sort"
 	"sync"
+	"time"
 )
 
 type OutputConsumer struct {
 	Output
+	Run OutputRun
 
-	groups map[File][]runtime.Object
-	mutex  sync.Mutex
+	groups   map[File][]runtime.Object
+	outputs  map[File]Output
+	produced map[File][]File
+	mutex    sync.Mutex
 }
 
-func (instance *OutputConsumer) Consume(context runtime.Object) error {
+func (instance *OutputConsumer) Consume(context runtime.Object) ([]File, error) {
 	instance.mutex.Lock()
 	defer instance.mutex.Unlock()
 
-	f, err := instance.File.Apply(context)
+	targets, err := instance.targets(newOutputGroupingContext(context, instance.Run))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if instance.groups == nil {
 		instance.groups = make(map[File][]runtime.Object)
+		instance.outputs = make(map[File]Output)
 	}
 
-	group := instance.groups[f]
-	group = append(group, context)
-	instance.groups[f] = group
+	result := make([]File, len(targets))
+	for i, target := range targets {
+		if err := instance.consumeInto(target, context); err != nil {
+			return nil, err
+		}
+		result[i] = target.File
+	}
+	return result, nil
+}
+
+func (instance *OutputConsumer) consumeInto(target OutputTarget, context runtime.Object) error {
+	f := target.File
+	output := instance.Output
+	if target.Format != nil {
+		output.Format = *target.Format
+	}
+	if target.Bundling != nil {
+		output.Bundling = *target.Bundling
+	}
+	output = output.resolvedFor(f, target.Bundling != nil)
+	if existing, ok := instance.outputs[f]; ok && (existing.Format != output.Format || existing.Bundling != output.Bundling) {
+		return fmt.Errorf("conflicting format or bundling for output file %v", f)
+	}
+	instance.outputs[f] = output
 
+	instance.groups[f] = append(instance.groups[f], context)
 	return nil
 }
 
+func (instance *OutputConsumer) Ensure(f File) {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+
+	if instance.groups == nil {
+		instance.groups = make(map[File][]runtime.Object)
+		instance.outputs = make(map[File]Output)
+	}
+	if _, ok := instance.groups[f]; !ok {
+		instance.groups[f] = []runtime.Object{}
+	}
+}
+
+func (instance *OutputConsumer) Files() []File {
+	instance.mutex.Lock()
+	defer instance.mutex.Unlock()
+
+	if instance.Archive != "" {
+		return []File{instance.Archive}
+	}
+
+	unique := make(map[File]bool, len(instance.groups))
+	for f, values := range instance.groups {
+		if len(values) == 0 {
+			continue
+		}
+		if produced, ok := instance.produced[f]; ok {
+			for _, candidate := range produced {
+				unique[candidate] = true
+			}
+		} else {
+			unique[f] = true
+		}
+	}
+	result := make([]File, 0, len(unique))
+	for f := range unique {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
 func (instance *OutputConsumer) Finalize() error {
+	return instance.FinalizeWhere(nil)
+}
+
+func (instance *OutputConsumer) FinalizeWhere(predicate func(File) bool) error {
 	instance.mutex.Lock()
 	defer instance.mutex.Unlock()
 
+	if instance.Archive != "" {
+		return instance.writeArchive()
+	}
+
+	if instance.produced == nil {
+		instance.produced = make(map[File][]File)
+	}
 	if groups := instance.groups; groups != nil {
 		for f, values := range groups {
-			if err := instance.writeGroup(f, values); err != nil {
+			sink := instance.openFile
+			if predicate != nil && !predicate(f) {
+				sink = discardingSink
+			}
+			if err := instance.writeGroup(f, values, instance.recordingSink(f, sink)); err != nil {
 				return err
 			}
 		}
@@ -50,17 +138,165 @@ func (instance *OutputConsumer) Finalize() error {
 	return nil
 }
 
-func (instance *OutputConsumer) writeGroup(f File, values []runtime.Object) error {
+type outputSink func(f File) (io.WriteCloser, error)
+
+func discardingSink(File) (io.WriteCloser, error) {
+	return &discardingWriter{}, nil
+}
+
+type discardingWriter struct {
+}
+
+func (instance *discardingWriter) Write(p []byte) (n int, err error) {
+	return len(p), nil
+}
+
+func (instance *discardingWriter) Close() error {
+	return nil
+}
+
+func (instance *OutputConsumer) recordingSink(group File, sink outputSink) outputSink {
+	instance.produced[group] = nil
+	return func(f File) (io.WriteCloser, error) {
+		instance.produced[group] = append(instance.produced[group], f)
+		return sink(f)
+	}
+}
+
+func (instance *OutputConsumer) openFile(f File) (io.WriteCloser, error) {
+	w, err := instance.open(f)
+	if err != nil {
+		return nil, err
+	}
+	return compressorFor(f, w), nil
+}
+
+func (instance *OutputConsumer) outputOf(f File) Output {
+	if output, ok := instance.outputs[f]; ok {
+		return output
+	}
+	return instance.Output.resolvedFor(f, false)
+}
+
+func (instance *OutputConsumer) writeGroup(f File, values []runtime.Object, sink outputSink) error {
+	delegate := &OutputConsumer{
+		Output: instance.outputOf(f),
+		Run:    instance.Run,
+	}
+	return delegate.write(f, values, sink)
+}
+
+func (instance *OutputConsumer) write(f File, values []runtime.Object, sink outputSink) error {
+	switch instance.Format {
+	case OutputFormatKustomize:
+		return instance.writeGroupAsKustomization(f, values, sink)
+	case OutputFormatTerraform, OutputFormatTerraformJson:
+		return instance.writeGroupAsTerraform(f, values, sink)
+	case OutputFormatHelm:
+		return instance.writeGroupAsHelmValues(f, values, sink)
+	}
+
+	w, err := sink(f)
+	if err != nil {
+		return err
+	}
+	if err := instance.encodeGroup(f, values, w); err != nil {
+		abortWriter(w)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	return nil
+}
+
+func (instance *OutputConsumer) writeArchive() error {
+	format, err := instance.ArchiveFormat.resolveFor(instance.Archive)
+	if err != nil {
+		return err
+	}
+	w, err := instance.open(instance.Archive)
+	if err != nil {
+		return err
+	}
+	if err := instance.writeArchiveTo(w, format); err != nil {
+		abortWriter(w)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot write archive %v: %w", instance.Archive, err)
+	}
+	return nil
+}
+
+func (instance *OutputConsumer) writeArchiveTo(w io.Writer, format ArchiveFormat) error {
+	aw, err := format.newWriter(w)
+	if err != nil {
+		return fmt.Errorf("cannot write archive %v: %w", instance.Archive, err)
+	}
+
+	files := make([]File, 0, len(instance.groups))
+	for f := range instance.groups {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i] < files[j]
+	})
+
+	modified := time.Now()
+	root := instance.archiveRoot()
+	sink := func(f File) (io.WriteCloser, error) {
+		return compressorFor(f, &archiveEntryWriter{
+			archive:  aw,
+			name:     archivePathOf(f, instance.Format, root),
+			modified: modified,
+		}), nil
+	}
+	for _, f := range files {
+		if err := instance.writeGroup(f, instance.groups[f], sink); err != nil {
+			return fmt.Errorf("cannot add %v to archive %v: %w", f, instance.Archive, err)
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return fmt.Errorf("cannot write archive %v: %w", instance.Archive, err)
+	}
+	return nil
+}
+
+func (instance *OutputConsumer) encodeGroup(f File, values []runtime.Object, w io.Writer) error {
 	switch instance.Format {
 	case OutputFormatYaml:
-		return instance.writeGroupAsYaml(f, values)
+		return instance.writeGroupAsYaml(f, values, w)
 	case OutputFormatJson:
-		return instance.writeGroupAsJson(f, values)
+		return instance.writeGroupAsJson(f, values, w)
+	case OutputFormatExternalSecret:
+		converted, err := instance.convertGroup(values, instance.ExternalSecret.convert)
+		if err != nil {
+			return fmt.Errorf("cannot write output file %v: %w", f, err)
+		}
+		return instance.writeGroupAsYaml(f, converted, w)
+	case OutputFormatTemplate:
+		return instance.writeGroupAsTemplate(f, values, w)
+	case OutputFormatEnv:
+		return instance.writeGroupAsEnv(f, values, w)
 	default:
 		return fmt.Errorf("cannot handle output format: %v", instance.Format)
 	}
 }
 
+func (instance *OutputConsumer) convertGroup(values []runtime.Object, converter func(runtime.Object) (runtime.Object, error)) ([]runtime.Object, error) {
+	result := make([]runtime.Object, len(values))
+	for i, value := range values {
+		converted, err := converter(value)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
+
 func (instance *OutputConsumer) toList(values []runtime.Object) (result *v1.List) {
 	result = new(v1.List)
 	result.Kind = "List"
@@ -72,13 +308,7 @@ func (instance *OutputConsumer) toList(values []runtime.Object) (result *v1.List
 	return
 }
 
-func (instance *OutputConsumer) writeGroupAsYaml(f File, values []runtime.Object) error {
-	w, err := f.Open()
-	if err != nil {
-		return err
-	}
-	defer func() { _ = w.Close() }()
-
+func (instance *OutputConsumer) writeGroupAsYaml(f File, values []runtime.Object, w io.Writer) error {
 	enc := json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, json.SerializerOptions{Yaml: true, Pretty: true, Strict: true})
 	switch instance.Bundling {
 	case OutputBundlingSeparation:
@@ -101,13 +331,7 @@ func (instance *OutputConsumer) writeGroupAsYaml(f File, values []runtime.Object
 	return nil
 }
 
-func (instance *OutputConsumer) writeGroupAsJson(f File, values []runtime.Object) error {
-	w, err := f.Open()
-	if err != nil {
-		return err
-	}
-	defer func() { _ = w.Close() }()
-
+func (instance *OutputConsumer) writeGroupAsJson(f File, values []runtime.Object, w io.Writer) error {
 	enc := json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, json.SerializerOptions{Pretty: instance.Bundling != OutputBundlingSeparation, Strict: true})
 	switch instance.Bundling {
 	case OutputBundlingSeparation:
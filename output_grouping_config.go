@@ -0,0 +1,146 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+type OutputGroupingConfig struct {
+	file      string
+	digest    string
+	groupings OutputGroupings
+}
+
+type outputGroupingConfigPayload struct {
+	Rules []outputGroupingConfigRule `yaml:"rules"`
+}
+
+type outputGroupingConfigRule struct {
+	Projection string `yaml:"projection"`
+	Matcher    string `yaml:"matcher"`
+	File       string `yaml:"file"`
+	Format     string `yaml:"format"`
+	Bundling   string `yaml:"bundling"`
+}
+
+func (instance *OutputGroupingConfig) Set(plain string) error {
+	if strings.TrimSpace(plain) == "" {
+		*instance = OutputGroupingConfig{}
+		return nil
+	}
+	content, err := ioutil.ReadFile(plain)
+	if err != nil {
+		return fmt.Errorf("cannot read output grouping config: %w", err)
+	}
+	groupings, err := parseOutputGroupingConfig(plain, content)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(content)
+	*instance = OutputGroupingConfig{
+		file:      plain,
+		digest:    hex.EncodeToString(digest[:]),
+		groupings: groupings,
+	}
+	return nil
+}
+
+func (instance OutputGroupingConfig) String() string {
+	return instance.file
+}
+
+func (instance OutputGroupingConfig) IsEmpty() bool {
+	return instance.file == ""
+}
+
+func (instance OutputGroupingConfig) fingerprint() string {
+	if instance.IsEmpty() {
+		return ""
+	}
+	return instance.file + "@" + instance.digest
+}
+
+func parseOutputGroupingConfig(name string, content []byte) (OutputGroupings, error) {
+	var payload outputGroupingConfigPayload
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&payload); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("illegal output grouping config %s: %w", name, err)
+	}
+	if len(payload.Rules) == 0 {
+		return nil, fmt.Errorf("illegal output grouping config %s: no rules defined", name)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("illegal output grouping config %s: %w", name, err)
+	}
+	lines := outputGroupingConfigRuleLinesOf(&document)
+	result := make(OutputGroupings, len(payload.Rules))
+	for i, rule := range payload.Rules {
+		if err := result[i].setRule(rule); err != nil {
+			if i < len(lines) {
+				return nil, fmt.Errorf("%s:%d: %w", name, lines[i], err)
+			}
+			return nil, fmt.Errorf("%s: rule #%d: %w", name, i+1, err)
+		}
+	}
+	return result, nil
+}
+
+func (instance *OutputGrouping) setRule(rule outputGroupingConfigRule) error {
+	if strings.TrimSpace(rule.File) == "" {
+		return fmt.Errorf("file of output grouping is required")
+	}
+	matcher := rule.Matcher
+	if matcher == "" {
+		matcher = ".*"
+	}
+	result, err := newOutputGrouping(rule.Projection, matcher, rule.File)
+	if err != nil {
+		return err
+	}
+	if rule.Format != "" {
+		var format OutputFormat
+		if err := format.Set(rule.Format); err != nil {
+			return err
+		}
+		result.format = &format
+	}
+	if rule.Bundling != "" {
+		var bundling OutputBundling
+		if err := bundling.Set(rule.Bundling); err != nil {
+			return err
+		}
+		result.bundling = &bundling
+	}
+	*instance = result
+	return nil
+}
+
+func outputGroupingConfigRuleLinesOf(document *yaml.Node) []int {
+	root := document
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "rules" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		result := make([]int, len(root.Content[i+1].Content))
+		for j, rule := range root.Content[i+1].Content {
+			result[j] = rule.Line
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_parseOutputGroupingConfig_with_yaml(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	actual, err := parseOutputGroupingConfig("grouping.yaml", []byte(`# grouping rules
+rules:
+- projection: '{{.Namespace}},{{.Name}}'
+  matcher: '^(kube-system),(.+)$'
+  file: 'system/$2.json'
+  format: json
+  bundling: separation
+- file: '{{.Namespace}}.yaml'
+`))
+	g.Expect(err).To(BeNil())
+	g.Expect(actual).To(HaveLen(2))
+
+	system := newTestSecret("kube-system", "a=b", nil)
+	target, err := actual.Target(newOutputGroupingContext(&system, OutputRun{}))
+	g.Expect(err).To(BeNil())
+	g.Expect(target.File).To(Equal(File("system/a=b.json")))
+	g.Expect(*target.Format).To(Equal(OutputFormatJson))
+	g.Expect(*target.Bundling).To(Equal(OutputBundlingSeparation))
+
+	other := newTestSecret("ns1", "a", nil)
+	target, err = actual.Target(newOutputGroupingContext(&other, OutputRun{}))
+	g.Expect(err).To(BeNil())
+	g.Expect(target.File).To(Equal(File("ns1.yaml")))
+	g.Expect(target.Format).To(BeNil())
+	g.Expect(target.Bundling).To(BeNil())
+}
+
+func Test_parseOutputGroupingConfig_reports_line_of_illegal_yaml_rule(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := parseOutputGroupingConfig("grouping.yaml", []byte(`rules:
+  - file: a.yaml
+
+  # second rule
+  - projection: '{{.Name}}'
+    matcher: '(foo'
+    file: b.yaml
+`))
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(HavePrefix("grouping.yaml:5: illegal matcher of output grouping:"))
+}
+
+func Test_parseOutputGroupingConfig_reports_line_of_illegal_json_rule(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := parseOutputGroupingConfig("grouping.json", []byte(`{
+	"rules": [
+		{"file": "a.yaml"},
+		{
+			"file": "b.yaml",
+			"format": "foo"
+		}
+	]
+}`))
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(Equal("grouping.json:4: illegal output format: foo"))
+}
+
+func Test_parseOutputGroupingConfig_reports_unknown_fields(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := parseOutputGroupingConfig("grouping.yaml", []byte(`rules:
+- file: a.yaml
+  fromat: json
+`))
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(ContainSubstring("line 3: field fromat not found"))
+}
+
+func Test_OutputConsumer_uses_format_of_grouping_rule(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	groupings, err := parseOutputGroupingConfig("grouping.yaml", []byte(`rules:
+- projection: '{{.Namespace}}'
+  matcher: '^ns1$'
+  file: '`+filepath.Join(dir, "ns1.json")+`'
+  format: json
+- file: '`+filepath.Join(dir, "{{.Namespace}}.yaml")+`'
+`))
+	g.Expect(err).To(BeNil())
+	a := newTestSecret("ns1", "a", map[string]string{"foo": "bar"})
+	b := newTestSecret("ns2", "b", map[string]string{"foo": "bar"})
+	instance := &OutputConsumer{}
+	instance.Grouping = OutputGroupingConfig{file: "grouping.yaml", groupings: groupings}
+	_, err = instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1.json"))).To(HavePrefix(`{`))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns2.yaml"))).To(HavePrefix(`apiVersion: v1`))
+}
+
+func Test_parseOutputGroupingConfig_reports_line_of_illegal_flow_style_rule(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	_, err := parseOutputGroupingConfig("grouping.yaml", []byte(`rules: [
+  {file: a.yaml},
+  {file: b.yaml, bundling: foo}
+]
+`))
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(HavePrefix("grouping.yaml:3: "))
+}
+
+func Test_OutputGroupingConfig_fingerprint_changes_with_content(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "grouping.yaml")
+
+	g.Expect(ioutil.WriteFile(file, []byte("rules:\n- file: a.yaml\n"), 0644)).To(BeNil())
+	var first OutputGroupingConfig
+	g.Expect(first.Set(file)).To(BeNil())
+
+	g.Expect(ioutil.WriteFile(file, []byte("rules:\n- file: b.yaml\n"), 0644)).To(BeNil())
+	var second OutputGroupingConfig
+	g.Expect(second.Set(file)).To(BeNil())
+
+	g.Expect(first.String()).To(Equal(second.String()))
+	g.Expect(Output{Grouping: first}.fingerprint()).NotTo(Equal(Output{Grouping: second}.fingerprint()))
+}
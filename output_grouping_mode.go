@@ -0,0 +1,67 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OutputGroupingMode uint8
+
+const (
+	OutputGroupingModeFirst = OutputGroupingMode(0)
+	OutputGroupingModeAll   = OutputGroupingMode(1)
+)
+
+func (instance *OutputGroupingMode) Set(plain string) error {
+	if v, ok := nameToOutputGroupingMode[strings.ToLower(plain)]; ok {
+		*instance = v
+		return nil
+	}
+	return fmt.Errorf("illegal output grouping mode: %s", plain)
+}
+
+func (instance OutputGroupingMode) String() string {
+	if v, ok := outputGroupingModeToName[instance]; ok {
+		return v
+	}
+	return fmt.Sprintf("illegal output grouping mode: %d", instance)
+}
+
+type OutputGroupingModes []OutputGroupingMode
+
+func (instance OutputGroupingModes) String() string {
+	return strings.Join(instance.Strings(), ",")
+}
+
+func (instance OutputGroupingModes) Strings() []string {
+	strs := make([]string, len(instance))
+	for i, v := range instance {
+		strs[i] = v.String()
+	}
+	return strs
+}
+
+var (
+	outputGroupingModeToName = map[OutputGroupingMode]string{
+		OutputGroupingModeFirst: "first",
+		OutputGroupingModeAll:   "all",
+	}
+
+	nameToOutputGroupingMode = func(in map[OutputGroupingMode]string) map[string]OutputGroupingMode {
+		result := make(map[string]OutputGroupingMode)
+		for f, n := range in {
+			result[n] = f
+		}
+		return result
+	}(outputGroupingModeToName)
+
+	AllOutputGroupingModes = func(in map[OutputGroupingMode]string) OutputGroupingModes {
+		result := make(OutputGroupingModes, len(in))
+		var i int
+		for f := range in {
+			result[i] = f
+			i++
+		}
+		return result
+	}(outputGroupingModeToName)
+)
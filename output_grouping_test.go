@@ -0,0 +1,81 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+	"time"
+)
+
+func Test_OutputGroupings_Apply_uses_context_and_functions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	owned := newTestSecret("ns1", "Database", nil)
+	owned.Labels = map[string]string{"team": "payments"}
+	unowned := newTestSecret("ns1", "Cache", nil)
+	run := OutputRun{
+		Id:      "abc",
+		Cluster: "prod/eu",
+		Time:    time.Date(2020, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+	var instance OutputGroupings
+	g.Expect(instance.Set(`{{ .Cluster | safePath }}/{{ date "2006-01-02" .Time }}/{{ label "team" | default "unowned" }}/{{ .Name | lower }}.yaml`)).To(BeNil())
+
+	g.Expect(instance.Apply(newOutputGroupingContext(&owned, run))).To(Equal(File("prod_eu/2020-10-19/payments/database.yaml")))
+	g.Expect(instance.Apply(newOutputGroupingContext(&unowned, run))).To(Equal(File("prod_eu/2020-10-19/unowned/cache.yaml")))
+}
+
+func Test_OutputGroupings_Apply_provides_run_information(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secret := newTestSecret("ns1", "a", nil)
+	secret.Annotations = map[string]string{"owner": "x"}
+	var instance OutputGroupings
+	g.Expect(instance.Set(`{{ .Snapshot }}/{{ .RunId }}-{{ .Type | replace "/" "-" | trimPrefix "kubernetes.io-" }}-{{ annotation "owner" }}.yaml`)).To(BeNil())
+
+	g.Expect(instance.Apply(newOutputGroupingContext(&secret, OutputRun{Id: "abc"}))).To(Equal(File("./abc-Opaque-x.yaml")))
+}
+
+func Test_Template_Execute_without_grouping_context_rejects_label(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var instance Template
+	g.Expect(instance.Set(`{{ label "team" }}`)).To(BeNil())
+
+	_, err := instance.Execute(newTestSecret("ns1", "a", nil))
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(ContainSubstring("label is not available in this template"))
+}
+
+func Test_OutputGroupings_referencesField(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	cases := map[string]bool{
+		"{{.Namespace}}.yaml":                               false,
+		"snapshot/{{.Namespace}}.yaml":                      false,
+		"{{.Snapshot}}/{{.Namespace}}.yaml":                 true,
+		"{{ if .Namespace }}{{ $.Snapshot }}{{ end }}.yaml": true,
+		"{{ .Name }}=(.*)={{ .Snapshot }}/$1.yaml":          true,
+	}
+	for plain, expected := range cases {
+		var instance OutputGroupings
+		g.Expect(instance.Set(plain)).To(BeNil())
+		g.Expect(instance.referencesField("Snapshot")).To(Equal(expected), plain)
+	}
+}
+
+func Test_OutputGroupings_Targets_with_all_mode_returns_every_match(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secret := newTestSecret("ns1", "a", nil)
+	var instance OutputGroupings
+	g.Expect(instance.Set(`{{.Namespace}}=^ns1$=ns1.yaml,all.yaml,{{.Namespace}}=^ns1$=ns1.yaml`)).To(BeNil())
+	context := newOutputGroupingContext(&secret, OutputRun{})
+
+	first, err := instance.Targets(context, OutputGroupingModeFirst)
+	g.Expect(err).To(BeNil())
+	g.Expect(first).To(Equal([]OutputTarget{{File: "ns1.yaml"}}))
+
+	all, err := instance.Targets(context, OutputGroupingModeAll)
+	g.Expect(err).To(BeNil())
+	g.Expect(all).To(Equal([]OutputTarget{{File: "ns1.yaml"}, {File: "all.yaml"}}))
+}
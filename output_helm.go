@@ -0,0 +1,259 @@
+package kube_secrets_exporter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type HelmOutput struct {
+	Path          HelmValuesPath
+	ChartTemplate string
+}
+
+func (instance *HelmOutput) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("helm.").
+		EnvarNamePrefix("HELM_")
+
+	g.Flag("path", "Golang template of the dot separated path inside of the values where the keys of each secret are placed."+
+		" Available are all fields of the secret. The path is split at dots outside of '{{ }}' before it is evaluated,"+
+		" so dots inside of names or namespaces do not create nested values.").
+		Default("secrets.{{.Namespace}}.{{.Name}}").
+		Envar("PATH").
+		SetValue(&instance.Path)
+	g.Flag("chart-template", "If set a chart template which renders the values back into Secret objects is written to this"+
+		" path, relative to the values file; example 'templates/secrets.yaml'. The file name is prefixed with the name of"+
+		" each values file, so 'ns1.yaml' results in 'templates/ns1-secrets.yaml'.").
+		Envar("CHART_TEMPLATE").
+		StringVar(&instance.ChartTemplate)
+}
+
+type helmSecret struct {
+	secret *v1.Secret
+	path   []string
+	binary map[string]bool
+}
+
+type HelmValuesPath struct {
+	segments []Template
+	plain    string
+}
+
+func (instance *HelmValuesPath) Set(plain string) error {
+	if strings.TrimSpace(plain) == "" {
+		*instance = HelmValuesPath{}
+		return nil
+	}
+	elements := splitHelmValuesPath(strings.TrimSpace(plain))
+	segments := make([]Template, len(elements))
+	for i, element := range elements {
+		if strings.TrimSpace(element) == "" {
+			return fmt.Errorf("illegal values path '%s': empty element at position %d", plain, i+1)
+		}
+		if err := segments[i].Set(element); err != nil {
+			return fmt.Errorf("illegal values path '%s': %w", plain, err)
+		}
+	}
+	*instance = HelmValuesPath{
+		segments: segments,
+		plain:    plain,
+	}
+	return nil
+}
+
+func (instance HelmValuesPath) String() string {
+	return instance.plain
+}
+
+func (instance HelmValuesPath) IsEmpty() bool {
+	return len(instance.segments) == 0
+}
+
+func splitHelmValuesPath(plain string) []string {
+	var result []string
+	start, inAction := 0, false
+	for i := 0; i < len(plain); i++ {
+		switch {
+		case !inAction && strings.HasPrefix(plain[i:], "{{"):
+			inAction = true
+			i++
+		case inAction && strings.HasPrefix(plain[i:], "}}"):
+			inAction = false
+			i++
+		case !inAction && plain[i] == '.':
+			result = append(result, plain[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, plain[start:])
+}
+
+func (instance HelmOutput) pathOf(secret *v1.Secret) ([]string, error) {
+	if instance.Path.IsEmpty() {
+		return []string{"secrets", secret.Namespace, secret.Name}, nil
+	}
+	result := make([]string, len(instance.Path.segments))
+	for i, segment := range instance.Path.segments {
+		element, err := segment.Execute(secret)
+		if err != nil {
+			return nil, err
+		}
+		if element = strings.TrimSpace(element); element == "" {
+			return nil, fmt.Errorf("illegal values path '%s' for secret %s/%s: element %d is empty", instance.Path, secret.Namespace, secret.Name, i+1)
+		}
+		result[i] = element
+	}
+	return result, nil
+}
+
+func (instance *OutputConsumer) writeGroupAsHelmValues(f File, values []runtime.Object, sink outputSink) error {
+	if instance.Helm.ChartTemplate != "" && !f.IsRegular() && !f.IsS3() && instance.Archive == "" {
+		return fmt.Errorf("chart template of output format %v requires regular output files but got %v", instance.Format, f)
+	}
+
+	root := make(map[string]interface{})
+	secrets := make([]helmSecret, 0, len(values))
+	for _, value := range values {
+		secret, ok := value.(*v1.Secret)
+		if !ok {
+			return fmt.Errorf("cannot write %v as helm values", value.GetObjectKind().GroupVersionKind().Kind)
+		}
+		p, err := instance.Helm.pathOf(secret)
+		if err != nil {
+			return err
+		}
+		node, err := helmValuesNodeOf(root, p)
+		if err != nil {
+			return fmt.Errorf("cannot place secret %s/%s into values: %w", secret.Namespace, secret.Name, err)
+		}
+		entry := helmSecret{
+			secret: secret,
+			path:   p,
+			binary: make(map[string]bool),
+		}
+		for key, v := range secretDataOf(*secret) {
+			if _, exists := node[key]; exists {
+				return fmt.Errorf("cannot place secret %s/%s into values: %s.%s is already defined", secret.Namespace, secret.Name, strings.Join(p, "."), key)
+			}
+			if utf8.Valid(v) {
+				node[key] = string(v)
+			} else {
+				node[key] = base64.StdEncoding.EncodeToString(v)
+				entry.binary[key] = true
+			}
+		}
+		secrets = append(secrets, entry)
+	}
+
+	b, err := yaml.Marshal(root)
+	if err != nil {
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	if err := writeFileTo(f, b, sink); err != nil {
+		return err
+	}
+
+	if instance.Helm.ChartTemplate == "" {
+		return nil
+	}
+	chartTemplate, err := encodeHelmChartTemplate(secrets)
+	if err != nil {
+		return fmt.Errorf("cannot write chart template for %v: %w", f, err)
+	}
+	return writeFileTo(helmChartTemplateFileOf(f, instance.Helm.ChartTemplate), chartTemplate, sink)
+}
+
+func helmChartTemplateFileOf(f File, chartTemplate string) File {
+	name := path.Base(filepath.ToSlash(f.String()))
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	return siblingOf(f, path.Join(path.Dir(chartTemplate), name+"-"+path.Base(chartTemplate)))
+}
+
+func escapeHelmTemplate(plain string) string {
+	return strings.Replace(plain, "{{", `{{ "{{" }}`, -1)
+}
+
+func helmValuesNodeOf(root map[string]interface{}, path []string) (map[string]interface{}, error) {
+	node := root
+	for i, element := range path {
+		switch child := node[element].(type) {
+		case nil:
+			created := make(map[string]interface{})
+			node[element] = created
+			node = created
+		case map[string]interface{}:
+			node = child
+		default:
+			return nil, fmt.Errorf("%s is already defined as value", strings.Join(path[:i+1], "."))
+		}
+	}
+	return node, nil
+}
+
+func encodeHelmChartTemplate(secrets []helmSecret) ([]byte, error) {
+	result := new(strings.Builder)
+	for _, entry := range secrets {
+		secret := entry.secret
+		metadata := map[string]interface{}{
+			"name": secret.Name,
+		}
+		if secret.Namespace != "" {
+			metadata["namespace"] = secret.Namespace
+		}
+		if len(secret.Labels) > 0 {
+			metadata["labels"] = secret.Labels
+		}
+		if len(secret.Annotations) > 0 {
+			metadata["annotations"] = secret.Annotations
+		}
+		object := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   metadata,
+		}
+		if secret.Type != "" {
+			object["type"] = string(secret.Type)
+		}
+		header, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		result.WriteString("---\n")
+		result.WriteString(escapeHelmTemplate(string(header)))
+
+		keys := make([]string, 0, len(secret.Data)+len(secret.StringData))
+		for key := range secretDataOf(*secret) {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if len(keys) == 0 {
+			continue
+		}
+
+		result.WriteString("data:\n")
+		for _, key := range keys {
+			arguments := make([]string, 0, len(entry.path)+1)
+			for _, element := range entry.path {
+				arguments = append(arguments, strconv.Quote(element))
+			}
+			arguments = append(arguments, strconv.Quote(key))
+			pipeline := "index .Values " + strings.Join(arguments, " ")
+			if !entry.binary[key] {
+				pipeline += " | b64enc"
+			}
+			_, _ = fmt.Fprintf(result, "  %s: {{ %s | quote }}\n", escapeHelmTemplate(strconv.Quote(key)), pipeline)
+		}
+	}
+	return []byte(result.String()), nil
+}
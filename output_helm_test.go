@@ -0,0 +1,153 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OutputConsumer_with_helm_format_writes_values_and_chart_template(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "a", map[string]string{"password": "bar", "user": "foo"})
+	a.Labels = map[string]string{"team": "a"}
+	b := newTestSecret("ns2", "b", map[string]string{"raw": ""})
+	b.Data["raw"] = []byte{0xff, 0xfe}
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatHelm
+	instance.Helm.ChartTemplate = "templates/secrets.yaml"
+	g.Expect(instance.File.Set(filepath.Join(dir, "values.yaml"))).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "values.yaml"))).To(Equal([]byte(`secrets:
+  ns1:
+    a:
+      password: bar
+      user: foo
+  ns2:
+    b:
+      raw: //4=
+`)))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "templates", "values-secrets.yaml"))).To(Equal([]byte(`---
+apiVersion: v1
+kind: Secret
+metadata:
+  labels:
+    team: a
+  name: a
+  namespace: ns1
+type: Opaque
+data:
+  "password": {{ index .Values "secrets" "ns1" "a" "password" | b64enc | quote }}
+  "user": {{ index .Values "secrets" "ns1" "a" "user" | b64enc | quote }}
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: b
+  namespace: ns2
+type: Opaque
+data:
+  "raw": {{ index .Values "secrets" "ns2" "b" "raw" | quote }}
+`)))
+}
+
+func Test_OutputConsumer_with_helm_format_keeps_dotted_names_in_one_element(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "a", map[string]string{"b": "foo"})
+	b := newTestSecret("ns1", "a.b", map[string]string{"c": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatHelm
+	g.Expect(instance.Helm.Path.Set("secrets.{{.Namespace}}.{{.Name}}")).To(BeNil())
+	g.Expect(instance.File.Set(filepath.Join(dir, "values.yaml"))).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "values.yaml"))).To(Equal([]byte(`secrets:
+  ns1:
+    a:
+      b: foo
+    a.b:
+      c: bar
+`)))
+}
+
+func Test_OutputConsumer_with_helm_format_fails_on_conflicting_paths(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestSecret("ns1", "a", map[string]string{"b": "foo"})
+	b := newTestSecret("ns1", "b", map[string]string{"b": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatHelm
+	g.Expect(instance.Helm.Path.Set("secrets.{{.Namespace}}")).To(BeNil())
+	g.Expect(instance.File.Set(Stdout.String())).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	err = instance.Finalize()
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(ContainSubstring("secrets.ns1.b is already defined"))
+}
+
+func Test_HelmValuesPath_splits_outside_of_actions(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(splitHelmValuesPath("secrets.{{.Namespace}}.{{ .Name | replace \".\" \"_\" }}")).To(Equal([]string{
+		"secrets", "{{.Namespace}}", "{{ .Name | replace \".\" \"_\" }}",
+	}))
+
+	var instance HelmValuesPath
+	g.Expect(instance.Set("secrets..{{.Name}}")).NotTo(BeNil())
+}
+
+func Test_OutputConsumer_with_helm_format_writes_chart_template_per_group(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "a", map[string]string{"{{key}}": "foo"})
+	a.Annotations = map[string]string{"note": "{{ .Release.Name }}"}
+	b := newTestSecret("ns2", "b", map[string]string{"user": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatHelm
+	instance.Helm.ChartTemplate = "templates/secrets.yaml"
+	g.Expect(instance.File.Set(filepath.Join(dir, "{{.Namespace}}.yaml"))).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "templates", "ns1-secrets.yaml"))).To(Equal([]byte(`---
+apiVersion: v1
+kind: Secret
+metadata:
+  annotations:
+    note: '{{ "{{" }} .Release.Name }}'
+  name: a
+  namespace: ns1
+type: Opaque
+data:
+  "{{ "{{" }}key}}": {{ index .Values "secrets" "ns1" "a" "{{key}}" | b64enc | quote }}
+`)))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "templates", "ns2-secrets.yaml"))).To(ContainSubstring(`name: b`))
+}
@@ -0,0 +1,144 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var kustomizeEnvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+type kustomization struct {
+	ApiVersion      string                     `json:"apiVersion"`
+	Kind            string                     `json:"kind"`
+	SecretGenerator []kustomizeSecretGenerator `json:"secretGenerator"`
+}
+
+type kustomizeSecretGenerator struct {
+	Name      string                    `json:"name"`
+	Namespace string                    `json:"namespace,omitempty"`
+	Type      string                    `json:"type,omitempty"`
+	Envs      []string                  `json:"envs,omitempty"`
+	Files     []string                  `json:"files,omitempty"`
+	Options   kustomizeGeneratorOptions `json:"options"`
+}
+
+type kustomizeGeneratorOptions struct {
+	DisableNameSuffixHash bool              `json:"disableNameSuffixHash"`
+	Labels                map[string]string `json:"labels,omitempty"`
+	Annotations           map[string]string `json:"annotations,omitempty"`
+}
+
+func (instance *OutputConsumer) writeGroupAsKustomization(f File, values []runtime.Object, sink outputSink) error {
+	if !f.IsRegular() && !f.IsS3() && instance.Archive == "" {
+		return fmt.Errorf("output format %v requires regular output files but got %v", instance.Format, f)
+	}
+
+	result := kustomization{
+		ApiVersion:      "kustomize.config.k8s.io/v1beta1",
+		Kind:            "Kustomization",
+		SecretGenerator: make([]kustomizeSecretGenerator, 0, len(values)),
+	}
+	for _, value := range values {
+		secret, ok := value.(*v1.Secret)
+		if !ok {
+			return fmt.Errorf("cannot write %v as kustomize secret generator", value.GetObjectKind().GroupVersionKind().Kind)
+		}
+		generator, err := instance.writeKustomizeSecretFiles(f, secret, sink)
+		if err != nil {
+			return err
+		}
+		result.SecretGenerator = append(result.SecretGenerator, generator)
+	}
+
+	b, err := yaml.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	return writeFileTo(f, b, sink)
+}
+
+func (instance *OutputConsumer) writeKustomizeSecretFiles(f File, secret *v1.Secret, sink outputSink) (kustomizeSecretGenerator, error) {
+	result := kustomizeSecretGenerator{
+		Name:      secret.Name,
+		Namespace: secret.Namespace,
+		Type:      string(secret.Type),
+		Options: kustomizeGeneratorOptions{
+			DisableNameSuffixHash: true,
+			Labels:                secret.Labels,
+			Annotations:           secret.Annotations,
+		},
+	}
+
+	data := secretDataOf(*secret)
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	base := path.Join("secrets", secret.Namespace, secret.Name)
+	if isKustomizeEnvCompatible(data) {
+		env := new(strings.Builder)
+		for _, key := range keys {
+			env.WriteString(key + "=" + string(data[key]) + "\n")
+		}
+		result.Envs = []string{base + ".env"}
+		if err := writeFileTo(siblingOf(f, base+".env"), []byte(env.String()), sink); err != nil {
+			return result, err
+		}
+		return result, nil
+	}
+
+	for _, key := range keys {
+		rel := path.Join(base, key)
+		result.Files = append(result.Files, key+"="+rel)
+		if err := writeFileTo(siblingOf(f, rel), data[key], sink); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func isKustomizeEnvCompatible(data map[string][]byte) bool {
+	for key, value := range data {
+		if !kustomizeEnvKeyPattern.MatchString(key) || !utf8.Valid(value) {
+			return false
+		}
+		plain := string(value)
+		if strings.ContainsAny(plain, "\r\n") || strings.TrimSpace(plain) != plain {
+			return false
+		}
+	}
+	return true
+}
+
+func siblingOf(f File, rel string) File {
+	if f.IsS3() {
+		plain := f.String()[len(s3Scheme):]
+		return File(s3Scheme + path.Join(path.Dir(plain), rel))
+	}
+	return File(filepath.Join(filepath.Dir(f.String()), filepath.FromSlash(rel)))
+}
+
+func writeFileTo(f File, content []byte, sink outputSink) error {
+	w, err := sink(f)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		abortWriter(w)
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	return nil
+}
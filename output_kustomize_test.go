@@ -0,0 +1,73 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OutputConsumer_with_kustomize_format_writes_generator_and_files(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	env := newTestSecret("ns1", "env", map[string]string{"PASSWORD": "bar", "USER": "foo"})
+	env.Labels = map[string]string{"team": "a"}
+	tls := newTestSecret("ns1", "tls", map[string]string{"tls.crt": "line1\nline2"})
+	tls.Type = "kubernetes.io/tls"
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatKustomize
+	g.Expect(instance.File.Set(filepath.Join(dir, "{{.Namespace}}", "kustomization.yaml"))).To(BeNil())
+	_, err := instance.Consume(&env)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&tls)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1", "kustomization.yaml"))).To(Equal([]byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+secretGenerator:
+- envs:
+  - secrets/ns1/env.env
+  name: env
+  namespace: ns1
+  options:
+    disableNameSuffixHash: true
+    labels:
+      team: a
+  type: Opaque
+- files:
+  - tls.crt=secrets/ns1/tls/tls.crt
+  name: tls
+  namespace: ns1
+  options:
+    disableNameSuffixHash: true
+  type: kubernetes.io/tls
+`)))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1", "secrets", "ns1", "env.env"))).To(Equal([]byte("PASSWORD=bar\nUSER=foo\n")))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1", "secrets", "ns1", "tls", "tls.crt"))).To(Equal([]byte("line1\nline2")))
+}
+
+func Test_OutputConsumer_Files_contains_files_of_unchanged_groups(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	env := newTestSecret("ns1", "env", map[string]string{"USER": "foo"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatKustomize
+	g.Expect(instance.File.Set(filepath.Join(dir, "kustomization.yaml"))).To(BeNil())
+	_, err := instance.Consume(&env)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.FinalizeWhere(func(File) bool { return false })).To(BeNil())
+
+	g.Expect(filepath.Join(dir, "kustomization.yaml")).NotTo(BeAnExistingFile())
+	g.Expect(instance.Files()).To(Equal([]File{
+		File(filepath.Join(dir, "kustomization.yaml")),
+		File(filepath.Join(dir, "secrets", "ns1", "env.env")),
+	}))
+}
@@ -0,0 +1,49 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type OutputTemplateContext struct {
+	File    string
+	Secrets []OutputTemplateSecret
+}
+
+type OutputTemplateSecret struct {
+	*v1.Secret
+	Values map[string]string
+}
+
+func (instance *OutputConsumer) writeGroupAsTemplate(f File, values []runtime.Object, w io.Writer) error {
+	if instance.Template.IsEmpty() {
+		return fmt.Errorf("output format %v requires a template file", instance.Format)
+	}
+
+	context := OutputTemplateContext{
+		File:    f.String(),
+		Secrets: make([]OutputTemplateSecret, len(values)),
+	}
+	for i, value := range values {
+		secret, ok := value.(*v1.Secret)
+		if !ok {
+			return fmt.Errorf("cannot render %v using template", value.GetObjectKind().GroupVersionKind().Kind)
+		}
+		data := secretDataOf(*secret)
+		decoded := make(map[string]string, len(data))
+		for key, v := range data {
+			decoded[key] = string(v)
+		}
+		context.Secrets[i] = OutputTemplateSecret{
+			Secret: secret,
+			Values: decoded,
+		}
+	}
+
+	if err := instance.Template.Execute(w, context); err != nil {
+		return fmt.Errorf("cannot write output file %v: %w", f, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OutputConsumer_with_template_format_renders_group(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	templateFile := filepath.Join(dir, "output.tmpl")
+	g.Expect(ioutil.WriteFile(templateFile, []byte(`# {{.File}}
+{{- range .Secrets}}
+{{.Namespace}}/{{.Name}}:
+{{- range $key, $value := .Values}}
+  {{$key}}={{$value}} {{b64enc $value}} {{sha256 $value | printf "%.8s"}}
+{{- end}}
+{{toYaml .Labels | indent 2}}
+{{- end}}
+`), 0644)).To(BeNil())
+	a := newTestSecret("ns1", "a", map[string]string{"foo": "bar"})
+	a.Labels = map[string]string{"team": "a"}
+
+	buf := new(bytes.Buffer)
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatTemplate
+	g.Expect(instance.Template.Set(templateFile)).To(BeNil())
+	g.Expect(instance.encodeGroup("out.txt", []runtime.Object{&a}, buf)).To(BeNil())
+
+	g.Expect(buf.String()).To(Equal(`# out.txt
+ns1/a:
+  foo=bar YmFy fcde2b2e
+  team: a
+`))
+}
+
+func Test_OutputConsumer_with_template_format_requires_template(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestSecret("ns1", "a", map[string]string{"foo": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatTemplate
+
+	g.Expect(instance.encodeGroup("out.txt", []runtime.Object{&a}, new(bytes.Buffer))).NotTo(BeNil())
+}
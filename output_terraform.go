@@ -0,0 +1,275 @@
+package kube_secrets_exporter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var terraformIllegalNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+type terraformSecret struct {
+	resourceName string
+	secret       *v1.Secret
+	data         map[string]string
+	binaryData   map[string]string
+}
+
+func (instance terraformSecret) address() string {
+	return "kubernetes_secret." + instance.resourceName
+}
+
+func (instance terraformSecret) importId() string {
+	return instance.secret.Namespace + "/" + instance.secret.Name
+}
+
+func terraformSecretsOf(values []runtime.Object) ([]terraformSecret, error) {
+	result := make([]terraformSecret, 0, len(values))
+	seen := make(map[string]bool)
+	for _, value := range values {
+		secret, ok := value.(*v1.Secret)
+		if !ok {
+			return nil, fmt.Errorf("cannot write %v as terraform resource", value.GetObjectKind().GroupVersionKind().Kind)
+		}
+		name := terraformResourceNameOf(secret)
+		for i, candidate := 2, name; ; i++ {
+			if !seen[candidate] {
+				name = candidate
+				break
+			}
+			candidate = fmt.Sprintf("%s_%d", name, i)
+		}
+		seen[name] = true
+
+		entry := terraformSecret{
+			resourceName: name,
+			secret:       secret,
+			data:         make(map[string]string),
+			binaryData:   make(map[string]string),
+		}
+		for key, v := range secretDataOf(*secret) {
+			if utf8.Valid(v) {
+				entry.data[key] = string(v)
+			} else {
+				entry.binaryData[key] = base64.StdEncoding.EncodeToString(v)
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func terraformResourceNameOf(secret *v1.Secret) string {
+	plain := secret.Name
+	if secret.Namespace != "" {
+		plain = secret.Namespace + "_" + plain
+	}
+	result := terraformIllegalNameCharacters.ReplaceAllString(plain, "_")
+	if result == "" || (result[0] >= '0' && result[0] <= '9') || result[0] == '-' {
+		result = "_" + result
+	}
+	return result
+}
+
+func terraformImportsFileOf(f File) File {
+	plain := f.String()
+	if i := strings.LastIndex(plain, ".tf"); i >= 0 {
+		return File(plain[:i] + ".imports" + plain[i:])
+	}
+	return File(plain + ".imports")
+}
+
+func (instance *OutputConsumer) writeGroupAsTerraform(f File, values []runtime.Object, sink outputSink) error {
+	secrets, err := terraformSecretsOf(values)
+	if err != nil {
+		return err
+	}
+
+	combined := !f.IsRegular() && !f.IsS3() && instance.Archive == ""
+	var resources, imports []byte
+	if instance.Format == OutputFormatTerraformJson {
+		resources, imports, err = encodeTerraformJson(secrets, combined)
+		if err != nil {
+			return fmt.Errorf("cannot write output file %v: %w", f, err)
+		}
+	} else {
+		resources, imports = encodeTerraformHcl(secrets)
+	}
+
+	if combined {
+		if len(imports) == 0 {
+			return writeFileTo(f, resources, sink)
+		}
+		return writeFileTo(f, append(append(resources, '\n'), imports...), sink)
+	}
+	if err := writeFileTo(f, resources, sink); err != nil {
+		return err
+	}
+	return writeFileTo(terraformImportsFileOf(f), imports, sink)
+}
+
+func encodeTerraformHcl(secrets []terraformSecret) (resources, imports []byte) {
+	r, i := new(strings.Builder), new(strings.Builder)
+	for n, entry := range secrets {
+		if n > 0 {
+			r.WriteString("\n")
+			i.WriteString("\n")
+		}
+		secret := entry.secret
+		_, _ = fmt.Fprintf(r, "resource \"kubernetes_secret\" %s {\n", strconv.Quote(entry.resourceName))
+		r.WriteString("  metadata {\n")
+		writeTerraformHclAttribute(r, "    ", "name", secret.Name)
+		if secret.Namespace != "" {
+			writeTerraformHclAttribute(r, "    ", "namespace", secret.Namespace)
+		}
+		writeTerraformHclMap(r, "    ", "labels", secret.Labels)
+		writeTerraformHclMap(r, "    ", "annotations", secret.Annotations)
+		r.WriteString("  }\n")
+		if secret.Type != "" {
+			writeTerraformHclAttribute(r, "  ", "type", string(secret.Type))
+		}
+		writeTerraformHclMap(r, "  ", "data", entry.data)
+		writeTerraformHclMap(r, "  ", "binary_data", entry.binaryData)
+		r.WriteString("}\n")
+
+		i.WriteString("import {\n")
+		_, _ = fmt.Fprintf(i, "  to = %s\n", entry.address())
+		writeTerraformHclAttribute(i, "  ", "id", entry.importId())
+		i.WriteString("}\n")
+	}
+	return []byte(r.String()), []byte(i.String())
+}
+
+func writeTerraformHclAttribute(w io.Writer, indent, name, value string) {
+	_, _ = fmt.Fprintf(w, "%s%s = %s\n", indent, name, quoteTerraformHcl(value))
+}
+
+func writeTerraformHclMap(w io.Writer, indent, name string, values map[string]string) {
+	if len(values) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	_, _ = fmt.Fprintf(w, "%s%s = {\n", indent, name)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "%s  %s = %s\n", indent, quoteTerraformHcl(key), quoteTerraformHcl(values[key]))
+	}
+	_, _ = fmt.Fprintf(w, "%s}\n", indent)
+}
+
+func quoteTerraformHcl(plain string) string {
+	result := new(strings.Builder)
+	result.WriteByte('"')
+	for i, r := range plain {
+		switch r {
+		case '"':
+			result.WriteString(`\"`)
+		case '\\':
+			result.WriteString(`\\`)
+		case '\n':
+			result.WriteString(`\n`)
+		case '\r':
+			result.WriteString(`\r`)
+		case '\t':
+			result.WriteString(`\t`)
+		case '$', '%':
+			result.WriteRune(r)
+			if i+1 < len(plain) && plain[i+1] == '{' {
+				result.WriteRune(r)
+			}
+		default:
+			if r < 0x20 {
+				_, _ = fmt.Fprintf(result, `\u%04X`, r)
+			} else {
+				result.WriteRune(r)
+			}
+		}
+	}
+	result.WriteByte('"')
+	return result.String()
+}
+
+var terraformTemplateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+func escapeTerraformTemplate(plain string) string {
+	return terraformTemplateEscaper.Replace(plain)
+}
+
+func escapeTerraformTemplates(values map[string]string) map[string]string {
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		result[escapeTerraformTemplate(key)] = escapeTerraformTemplate(value)
+	}
+	return result
+}
+
+func encodeTerraformJson(secrets []terraformSecret, combined bool) (resources, imports []byte, err error) {
+	resourceBlocks := make(map[string]interface{}, len(secrets))
+	importBlocks := make([]interface{}, len(secrets))
+	for i, entry := range secrets {
+		secret := entry.secret
+		metadata := map[string]interface{}{
+			"name": escapeTerraformTemplate(secret.Name),
+		}
+		if secret.Namespace != "" {
+			metadata["namespace"] = escapeTerraformTemplate(secret.Namespace)
+		}
+		if len(secret.Labels) > 0 {
+			metadata["labels"] = escapeTerraformTemplates(secret.Labels)
+		}
+		if len(secret.Annotations) > 0 {
+			metadata["annotations"] = escapeTerraformTemplates(secret.Annotations)
+		}
+		resource := map[string]interface{}{
+			"metadata": metadata,
+		}
+		if secret.Type != "" {
+			resource["type"] = escapeTerraformTemplate(string(secret.Type))
+		}
+		if len(entry.data) > 0 {
+			resource["data"] = escapeTerraformTemplates(entry.data)
+		}
+		if len(entry.binaryData) > 0 {
+			resource["binary_data"] = entry.binaryData
+		}
+		resourceBlocks[entry.resourceName] = resource
+		importBlocks[i] = map[string]interface{}{
+			"to": entry.address(),
+			"id": escapeTerraformTemplate(entry.importId()),
+		}
+	}
+
+	resourceDocument := map[string]interface{}{
+		"resource": map[string]interface{}{
+			"kubernetes_secret": resourceBlocks,
+		},
+	}
+	importDocument := map[string]interface{}{
+		"import": importBlocks,
+	}
+	if combined {
+		resourceDocument["import"] = importDocument["import"]
+		if resources, err = json.MarshalIndent(resourceDocument, "", "  "); err != nil {
+			return nil, nil, err
+		}
+		return append(resources, '\n'), nil, nil
+	}
+	if resources, err = json.MarshalIndent(resourceDocument, "", "  "); err != nil {
+		return nil, nil, err
+	}
+	if imports, err = json.MarshalIndent(importDocument, "", "  "); err != nil {
+		return nil, nil, err
+	}
+	return append(resources, '\n'), append(imports, '\n'), nil
+}
@@ -0,0 +1,145 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_OutputConsumer_with_terraform_format_writes_resources_and_imports(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "db.credentials", map[string]string{"password": "b\"a${r}"})
+	a.Labels = map[string]string{"team": "a"}
+	b := newTestSecret("ns1", "db_credentials", map[string]string{"raw": "x"})
+	b.Data["raw"] = []byte{0xff, 0xfe}
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatTerraform
+	g.Expect(instance.File.Set(filepath.Join(dir, "{{.Namespace}}.tf"))).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+	_, err = instance.Consume(&b)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1.tf"))).To(Equal([]byte(`resource "kubernetes_secret" "ns1_db_credentials" {
+  metadata {
+    name = "db.credentials"
+    namespace = "ns1"
+    labels = {
+      "team" = "a"
+    }
+  }
+  type = "Opaque"
+  data = {
+    "password" = "b\"a$${r}"
+  }
+}
+
+resource "kubernetes_secret" "ns1_db_credentials_2" {
+  metadata {
+    name = "db_credentials"
+    namespace = "ns1"
+  }
+  type = "Opaque"
+  binary_data = {
+    "raw" = "//4="
+  }
+}
+`)))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "ns1.imports.tf"))).To(Equal([]byte(`import {
+  to = kubernetes_secret.ns1_db_credentials
+  id = "ns1/db.credentials"
+}
+
+import {
+  to = kubernetes_secret.ns1_db_credentials_2
+  id = "ns1/db_credentials"
+}
+`)))
+}
+
+func Test_OutputConsumer_with_terraform_json_format_writes_resources_and_imports(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "1st", map[string]string{"foo": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatTerraformJson
+	g.Expect(instance.File.Set(filepath.Join(dir, "secrets.tf.json"))).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "secrets.tf.json"))).To(MatchJSON(`{"resource": {"kubernetes_secret": {"ns1_1st": {
+		"metadata": {"name": "1st", "namespace": "ns1"},
+		"type": "Opaque",
+		"data": {"foo": "bar"}
+	}}}}`))
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "secrets.imports.tf.json"))).To(MatchJSON(`{"import": [
+		{"to": "kubernetes_secret.ns1_1st", "id": "ns1/1st"}
+	]}`))
+}
+
+func Test_OutputConsumer_with_terraform_json_format_escapes_template_sequences(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a := newTestSecret("ns1", "1st", map[string]string{"fo${o}": "b%{a}r"})
+	a.Annotations = map[string]string{"note": "${var.x}"}
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatTerraformJson
+	g.Expect(instance.File.Set(filepath.Join(dir, "secrets.tf.json"))).To(BeNil())
+	_, err := instance.Consume(&a)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(instance.Finalize()).To(BeNil())
+
+	g.Expect(ioutil.ReadFile(filepath.Join(dir, "secrets.tf.json"))).To(MatchJSON(`{"resource": {"kubernetes_secret": {"ns1_1st": {
+		"metadata": {"name": "1st", "namespace": "ns1", "annotations": {"note": "$${var.x}"}},
+		"type": "Opaque",
+		"data": {"fo$${o}": "b%%{a}r"}
+	}}}}`))
+}
+
+func Test_OutputConsumer_with_terraform_json_format_writes_one_document_to_stdout(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a := newTestSecret("ns1", "1st", map[string]string{"foo": "bar"})
+	instance := &OutputConsumer{}
+	instance.Format = OutputFormatTerraformJson
+	buf := new(bytes.Buffer)
+	sink := func(File) (io.WriteCloser, error) {
+		return &bufferWriteCloser{buf}, nil
+	}
+
+	g.Expect(instance.writeGroupAsTerraform(Stdout, []runtime.Object{&a}, sink)).To(BeNil())
+
+	g.Expect(buf.String()).To(MatchJSON(`{
+		"resource": {"kubernetes_secret": {"ns1_1st": {
+			"metadata": {"name": "1st", "namespace": "ns1"},
+			"type": "Opaque",
+			"data": {"foo": "bar"}
+		}}},
+		"import": [{"to": "kubernetes_secret.ns1_1st", "id": "ns1/1st"}]
+	}`))
+}
+
+type bufferWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (instance *bufferWriteCloser) Close() error {
+	return nil
+}
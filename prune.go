@@ -0,0 +1,160 @@
+package kube_secrets_exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const pruneManifestName = ".kse-manifest.json"
+
+type Prune struct {
+	Root     string
+	Manifest string
+	DryRun   bool
+}
+
+func (instance *Prune) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("prune.").
+		EnvarNamePrefix("PRUNE_")
+
+	g.Flag("root", "Output root directory. If set, files below it which were produced by an earlier run"+
+		" but not by the current one are removed. Empty means no pruning.").
+		Envar("ROOT").
+		StringVar(&instance.Root)
+	g.Flag("manifest", "File which records the files produced by the last run."+
+		" Empty means '"+pruneManifestName+"' inside of the output root.").
+		Envar("MANIFEST").
+		StringVar(&instance.Manifest)
+	g.Flag("dry-run", "Only list the files which would be removed.").
+		Envar("DRY_RUN").
+		BoolVar(&instance.DryRun)
+}
+
+func (instance Prune) IsEnabled() bool {
+	return instance.Root != ""
+}
+
+func (instance Prune) manifestFile() string {
+	if v := instance.Manifest; v != "" {
+		return v
+	}
+	return filepath.Join(instance.Root, pruneManifestName)
+}
+
+type PruneManifest struct {
+	Files []string `json:"files"`
+}
+
+func (instance Prune) Apply(produced []File) (removed []File, err error) {
+	if !instance.IsEnabled() {
+		return nil, nil
+	}
+	root, err := filepath.Abs(instance.Root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve output root '%s': %w", instance.Root, err)
+	}
+
+	current := make(map[string]bool)
+	for _, f := range produced {
+		if rel, ok := relativeToRoot(root, f.String()); ok {
+			current[rel] = true
+		}
+	}
+
+	previous, err := instance.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range previous.Files {
+		if current[rel] {
+			continue
+		}
+		if err := instance.remove(root, rel); err != nil {
+			return removed, err
+		}
+		removed = append(removed, File(filepath.Join(root, filepath.FromSlash(rel))))
+	}
+
+	if instance.DryRun {
+		return nil, nil
+	}
+	return removed, instance.saveManifest(current)
+}
+
+func (instance Prune) remove(root, rel string) error {
+	if _, ok := relativeToRoot(root, filepath.Join(root, rel)); !ok {
+		return fmt.Errorf("manifest '%s' references file '%s' outside of output root", instance.manifestFile(), rel)
+	}
+	path := filepath.Join(root, rel)
+	if instance.DryRun {
+		log.Printf("would remove %s", path)
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove stale file '%s': %w", path, err)
+	}
+	log.Printf("removed %s", path)
+
+	for dir := filepath.Dir(path); dir != root; dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (instance Prune) loadManifest() (PruneManifest, error) {
+	var result PruneManifest
+	b, err := ioutil.ReadFile(instance.manifestFile())
+	if os.IsNotExist(err) {
+		return result, nil
+	} else if err != nil {
+		return result, fmt.Errorf("cannot read prune manifest '%s': %w", instance.manifestFile(), err)
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return result, fmt.Errorf("cannot parse prune manifest '%s': %w", instance.manifestFile(), err)
+	}
+	return result, nil
+}
+
+func (instance Prune) saveManifest(files map[string]bool) error {
+	manifest := PruneManifest{
+		Files: make([]string, 0, len(files)),
+	}
+	for rel := range files {
+		manifest.Files = append(manifest.Files, rel)
+	}
+	sort.Strings(manifest.Files)
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode prune manifest: %w", err)
+	}
+	path := instance.manifestFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("cannot ensure directory for '%s': %w", path, err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("cannot write prune manifest '%s': %w", path, err)
+	}
+	return nil
+}
+
+func relativeToRoot(root, path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,44 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Prune_removes_only_stale_files_of_manifest(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	ns1, ns2, foreign := filepath.Join(dir, "ns1.yaml"), filepath.Join(dir, "sub", "ns2.yaml"), filepath.Join(dir, "foreign.txt")
+	g.Expect(os.MkdirAll(filepath.Dir(ns2), 0755)).To(BeNil())
+	for _, f := range []string{ns1, ns2, foreign} {
+		g.Expect(ioutil.WriteFile(f, []byte{}, 0644)).To(BeNil())
+	}
+	instance := Prune{Root: dir}
+
+	g.Expect(instance.Apply([]File{File(ns1), File(ns2)})).To(BeEmpty())
+	g.Expect(instance.Apply([]File{File(ns1)})).To(Equal([]File{File(ns2)}))
+
+	g.Expect(ns1).To(BeAnExistingFile())
+	g.Expect(ns2).NotTo(BeAnExistingFile())
+	g.Expect(filepath.Dir(ns2)).NotTo(BeADirectory())
+	g.Expect(foreign).To(BeAnExistingFile())
+}
+
+func Test_Prune_with_dryRun_keeps_files(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	ns1 := filepath.Join(dir, "ns1.yaml")
+	g.Expect(ioutil.WriteFile(ns1, []byte{}, 0644)).To(BeNil())
+
+	g.Expect(Prune{Root: dir}.Apply([]File{File(ns1)})).To(BeEmpty())
+	g.Expect(Prune{Root: dir, DryRun: true}.Apply(nil)).To(BeEmpty())
+
+	g.Expect(ns1).To(BeAnExistingFile())
+}
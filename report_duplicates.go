@@ -0,0 +1,136 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"sort"
+	"text/tabwriter"
+)
+
+type DuplicatesReport struct {
+	Format       ReportFormat
+	Output       File
+	IgnoreKeys   []string
+	MinGroupSize int
+}
+
+func (instance *DuplicatesReport) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("report.duplicates.").
+		EnvarNamePrefix("REPORT_DUPLICATES_")
+
+	g.Flag("format", fmt.Sprintf("Which format should be used for the report. Can be: %v", AllReportFormats.String())).
+		Default(ReportFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+	g.Flag("output", "Where to write the report to. It can be a regular file, 'stdout' or 'stderr'.").
+		Default(Stdout.String()).
+		Envar("OUTPUT").
+		SetValue(&instance.Output)
+	g.Flag("ignore-key", "Keys whose values are expected to be shared and should be ignored.").
+		Default("ca.crt", "service-ca.crt", "namespace").
+		Envar("IGNORE_KEY").
+		StringsVar(&instance.IgnoreKeys)
+	g.Flag("min-group-size", "Minimum number of occurrences of the same value to be reported.").
+		Default("2").
+		Envar("MIN_GROUP_SIZE").
+		IntVar(&instance.MinGroupSize)
+}
+
+type DuplicateValueGroup struct {
+	Group       int                        `json:"group"`
+	Occurrences []DuplicateValueOccurrence `json:"occurrences"`
+}
+
+type DuplicateValueOccurrence struct {
+	Secret Identifier `json:"secret"`
+	Key    string     `json:"key"`
+}
+
+func (instance DuplicatesReport) Execute(exporter *KubeSecretsExporter) error {
+	var secrets []v1.Secret
+	if err := exporter.VisitSecrets(context.Background(), &exporter.Environment, func(secret v1.Secret, _ string) error {
+		secrets = append(secrets, secret)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return instance.write(exporter.Output.open, instance.find(secrets))
+}
+
+func (instance DuplicatesReport) find(secrets []v1.Secret) []DuplicateValueGroup {
+	ignored := make(map[string]bool, len(instance.IgnoreKeys))
+	for _, key := range instance.IgnoreKeys {
+		ignored[key] = true
+	}
+
+	byHash := make(map[[sha256.Size]byte][]DuplicateValueOccurrence)
+	for _, secret := range secrets {
+		for key, value := range secretDataOf(secret) {
+			if ignored[key] || len(value) == 0 {
+				continue
+			}
+			hash := sha256.Sum256(value)
+			byHash[hash] = append(byHash[hash], DuplicateValueOccurrence{
+				Secret: identifierOf(secret),
+				Key:    key,
+			})
+		}
+	}
+
+	minGroupSize := instance.MinGroupSize
+	if minGroupSize < 2 {
+		minGroupSize = 2
+	}
+	result := []DuplicateValueGroup{}
+	for _, occurrences := range byHash {
+		if len(occurrences) < minGroupSize {
+			continue
+		}
+		sort.Slice(occurrences, func(i, j int) bool {
+			if occurrences[i].Secret != occurrences[j].Secret {
+				return occurrences[i].Secret < occurrences[j].Secret
+			}
+			return occurrences[i].Key < occurrences[j].Key
+		})
+		result = append(result, DuplicateValueGroup{Occurrences: occurrences})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].Occurrences) != len(result[j].Occurrences) {
+			return len(result[i].Occurrences) > len(result[j].Occurrences)
+		}
+		first, second := result[i].Occurrences[0], result[j].Occurrences[0]
+		if first.Secret != second.Secret {
+			return first.Secret < second.Secret
+		}
+		return first.Key < second.Key
+	})
+	for i := range result {
+		result[i].Group = i + 1
+	}
+	return result
+}
+
+func (instance DuplicatesReport) write(open outputSink, groups []DuplicateValueGroup) error {
+	return writeReport(open, instance.Output, instance.Format, func(w io.Writer) error {
+		return writeDuplicateValuesTable(groups, w)
+	}, groups)
+}
+
+func writeDuplicateValuesTable(groups []DuplicateValueGroup, to io.Writer) error {
+	w := tabwriter.NewWriter(to, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "GROUP\tSECRET\tKEY\n"); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		for _, occurrence := range group.Occurrences {
+			if _, err := fmt.Fprintf(w, "%d\t%v\t%s\n", group.Group, occurrence.Secret, occurrence.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,34 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"testing"
+)
+
+func Test_DuplicatesReport_find_groups_identical_values(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secrets := []v1.Secret{
+		newTestSecret("ns1", "a", map[string]string{"password": "secret", "ca.crt": "ca", "empty": ""}),
+		newTestSecret("ns2", "b", map[string]string{"db-password": "secret", "ca.crt": "ca", "token": "x", "empty": ""}),
+		newTestSecret("ns3", "c", map[string]string{"password": "secret", "token": "x"}),
+		newTestSecret("ns4", "d", map[string]string{"password": "unique"}),
+	}
+
+	actual := DuplicatesReport{IgnoreKeys: []string{"ca.crt"}, MinGroupSize: 2}.find(secrets)
+
+	buf := new(bytes.Buffer)
+	g.Expect(writeDuplicateValuesTable(actual, buf)).To(BeNil())
+	g.Expect(buf.String()).To(Equal(`GROUP  SECRET  KEY
+1      ns1/a   password
+1      ns2/b   db-password
+1      ns3/c   password
+2      ns2/b   token
+2      ns3/c   token
+`))
+	g.Expect(buf.String()).NotTo(ContainSubstring("secret\n"))
+
+	g.Expect(DuplicatesReport{MinGroupSize: 3}.find(secrets)).To(HaveLen(1))
+}
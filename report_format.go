@@ -0,0 +1,103 @@
+package kube_secrets_exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type ReportFormat uint8
+
+const (
+	ReportFormatTable = ReportFormat(0)
+	ReportFormatJson  = ReportFormat(1)
+)
+
+func (instance *ReportFormat) Set(plain string) error {
+	if v, ok := nameToReportFormat[strings.ToLower(plain)]; ok {
+		*instance = v
+		return nil
+	}
+	return fmt.Errorf("illegal report format: %s", plain)
+}
+
+func (instance ReportFormat) String() string {
+	if v, ok := reportFormatToName[instance]; ok {
+		return v
+	}
+	return fmt.Sprintf("illegal report format: %d", instance)
+}
+
+type ReportFormats []ReportFormat
+
+func (instance ReportFormats) String() string {
+	return strings.Join(instance.Strings(), ",")
+}
+
+func (instance ReportFormats) Strings() []string {
+	strs := make([]string, len(instance))
+	for i, v := range instance {
+		strs[i] = v.String()
+	}
+	return strs
+}
+
+var (
+	reportFormatToName = map[ReportFormat]string{
+		ReportFormatTable: "table",
+		ReportFormatJson:  "json",
+	}
+
+	nameToReportFormat = func(in map[ReportFormat]string) map[string]ReportFormat {
+		result := make(map[string]ReportFormat)
+		for f, n := range in {
+			result[n] = f
+		}
+		return result
+	}(reportFormatToName)
+
+	AllReportFormats = func(in map[ReportFormat]string) ReportFormats {
+		result := make(ReportFormats, len(in))
+		var i int
+		for f := range in {
+			result[i] = f
+			i++
+		}
+		return result
+	}(reportFormatToName)
+)
+
+func writeReport(open outputSink, output File, format ReportFormat, table func(io.Writer) error, value interface{}) error {
+	switch format {
+	case ReportFormatTable:
+		return writeReportTo(open, output, "report", table)
+	case ReportFormatJson:
+		return writeReportTo(open, output, "report", func(w io.Writer) error {
+			return encodeReportJson(w, value)
+		})
+	default:
+		return fmt.Errorf("cannot handle report format: %v", format)
+	}
+}
+
+func writeReportTo(open outputSink, output File, what string, write func(io.Writer) error) error {
+	w, err := open(output)
+	if err != nil {
+		return err
+	}
+	if err := write(w); err != nil {
+		abortWriter(w)
+		return fmt.Errorf("cannot write %s %v: %w", what, output, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot write %s %v: %w", what, output, err)
+	}
+	return nil
+}
+
+func encodeReportJson(w io.Writer, value interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
@@ -0,0 +1,185 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type UnusedReport struct {
+	Format ReportFormat
+	Output File
+
+	now func() time.Time
+}
+
+func (instance *UnusedReport) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("report.unused.").
+		EnvarNamePrefix("REPORT_UNUSED_")
+
+	g.Flag("format", fmt.Sprintf("Which format should be used for the report. Can be: %v", AllReportFormats.String())).
+		Default(ReportFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+	g.Flag("output", "Where to write the report to. It can be a regular file, 'stdout' or 'stderr'.").
+		Default(Stdout.String()).
+		Envar("OUTPUT").
+		SetValue(&instance.Output)
+}
+
+type UnusedSecret struct {
+	Secret        Identifier `json:"secret"`
+	Type          string     `json:"type"`
+	Unused        bool       `json:"unused"`
+	MissingOwners []string   `json:"missingOwners,omitempty"`
+	Created       time.Time  `json:"created"`
+	Age           string     `json:"age"`
+}
+
+func (instance UnusedSecret) reasons() string {
+	var result []string
+	if instance.Unused {
+		result = append(result, "unused")
+	}
+	if len(instance.MissingOwners) > 0 {
+		result = append(result, "orphaned ("+strings.Join(instance.MissingOwners, ",")+")")
+	}
+	return strings.Join(result, ", ")
+}
+
+type ownerLookup func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error)
+
+func (instance UnusedReport) Execute(exporter *KubeSecretsExporter) error {
+	client, err := exporter.Environment.NewClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	dynamicClient, err := exporter.Environment.NewDynamicClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	return instance.execute(context.Background(), exporter, client, dynamicClient)
+}
+
+func (instance UnusedReport) execute(ctx context.Context, exporter *KubeSecretsExporter, client kubernetes.Interface, dynamicClient dynamic.Interface) error {
+	namespace := exporter.Environment.Namespace
+	usages, err := collectSecretUsages(ctx, client, dynamicClient, namespace, int64(exporter.PageSize))
+	if err != nil {
+		return err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client.Discovery()))
+
+	secrets, err := exporter.selectedSecretsOf(ctx, client.CoreV1().Secrets(namespace))
+	if err != nil {
+		return err
+	}
+
+	result, err := instance.find(ctx, secrets, usages, newOwnerLookup(mapper, dynamicClient))
+	if err != nil {
+		return err
+	}
+	return instance.write(exporter.Output.open, result)
+}
+
+func newOwnerLookup(mapper meta.RESTMapper, client dynamic.Interface) ownerLookup {
+	return func(ctx context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			return false, fmt.Errorf("illegal owner reference %s/%s: %w", ref.Kind, ref.Name, err)
+		}
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("cannot resolve owner reference %s/%s: %w", ref.Kind, ref.Name, err)
+		}
+		var resources dynamic.ResourceInterface = client.Resource(mapping.Resource)
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			resources = client.Resource(mapping.Resource).Namespace(namespace)
+		}
+		owner, err := resources.Get(ctx, ref.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("cannot retrieve owner %s/%s from kubernetes: %w", ref.Kind, ref.Name, err)
+		}
+		return ref.UID == "" || owner.GetUID() == ref.UID, nil
+	}
+}
+
+func (instance UnusedReport) find(ctx context.Context, secrets []v1.Secret, usages SecretUsages, lookup ownerLookup) ([]UnusedSecret, error) {
+	now := time.Now
+	if instance.now != nil {
+		now = instance.now
+	}
+	existing := make(map[types.UID]bool)
+	result := []UnusedSecret{}
+	for _, secret := range secrets {
+		id := identifierOf(secret)
+		entry := UnusedSecret{
+			Secret:  id,
+			Type:    string(secret.Type),
+			Unused:  len(usages[id]) == 0,
+			Created: secret.CreationTimestamp.Time,
+			Age:     duration.HumanDuration(now().Sub(secret.CreationTimestamp.Time)),
+		}
+		for _, ref := range secret.OwnerReferences {
+			exists, cached := existing[ref.UID]
+			if !cached || ref.UID == "" {
+				var err error
+				if exists, err = lookup(ctx, secret.Namespace, ref); err != nil {
+					return nil, err
+				}
+				existing[ref.UID] = exists
+			}
+			if !exists {
+				entry.MissingOwners = append(entry.MissingOwners, ref.Kind+"/"+ref.Name)
+			}
+		}
+		if len(secret.OwnerReferences) > 0 && len(entry.MissingOwners) == 0 {
+			entry.Unused = false
+		}
+		if entry.Unused || len(entry.MissingOwners) > 0 {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Secret < result[j].Secret
+	})
+	return result, nil
+}
+
+func (instance UnusedReport) write(open outputSink, secrets []UnusedSecret) error {
+	return writeReport(open, instance.Output, instance.Format, func(w io.Writer) error {
+		return writeUnusedSecretsTable(secrets, w)
+	}, secrets)
+}
+
+func writeUnusedSecretsTable(secrets []UnusedSecret, to io.Writer) error {
+	w := tabwriter.NewWriter(to, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SECRET\tTYPE\tAGE\tREASON\n"); err != nil {
+		return err
+	}
+	for _, secret := range secrets {
+		if _, err := fmt.Fprintf(w, "%v\t%s\t%s\t%s\n", secret.Secret, secret.Type, secret.Age, secret.reasons()); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,98 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_UnusedReport_find_reports_unused_and_orphaned_secrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Date(2020, 10, 19, 12, 0, 0, 0, time.UTC)
+	newSecret := func(name string, age time.Duration, owners ...metav1.OwnerReference) v1.Secret {
+		result := newTestSecret("ns1", name, nil)
+		result.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		result.OwnerReferences = owners
+		return result
+	}
+	secrets := []v1.Secret{
+		newSecret("used", time.Hour),
+		newSecret("unused", 49*time.Hour),
+		newSecret("owned", time.Hour, metav1.OwnerReference{APIVersion: "cert-manager.io/v1", Kind: "Certificate", Name: "alive", UID: "1"}),
+		newSecret("orphaned", 10*time.Minute, metav1.OwnerReference{APIVersion: "cert-manager.io/v1", Kind: "Certificate", Name: "gone", UID: "2"}),
+	}
+	usages := SecretUsages{}
+	usages.add("ns1", "used", "Deployment", "web", SecretReferenceViaEnv)
+	usages.add("ns1", "orphaned", "Ingress", "web", SecretReferenceViaIngressTls)
+	lookup := func(_ context.Context, namespace string, ref metav1.OwnerReference) (bool, error) {
+		g.Expect(namespace).To(Equal("ns1"))
+		return ref.Name == "alive", nil
+	}
+
+	actual, err := UnusedReport{now: func() time.Time { return now }}.find(context.Background(), secrets, usages, lookup)
+	g.Expect(err).To(BeNil())
+
+	buf := new(bytes.Buffer)
+	g.Expect(writeUnusedSecretsTable(actual, buf)).To(BeNil())
+	g.Expect(buf.String()).To(Equal(`SECRET        TYPE    AGE   REASON
+ns1/orphaned  Opaque  10m   orphaned (Certificate/gone)
+ns1/unused    Opaque  2d1h  unused
+`))
+}
+
+func Test_UnusedReport_write_returns_error_of_close(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	buf := new(bytes.Buffer)
+	open := func(File) (io.WriteCloser, error) {
+		return &failingCloseWriter{buf}, nil
+	}
+	instance := UnusedReport{Output: Stdout}
+
+	err := instance.write(open, []UnusedSecret{{Secret: "ns1/a"}})
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(Equal("cannot write report stdout: close failed"))
+	g.Expect(buf.String()).To(ContainSubstring("ns1/a"))
+}
+
+type failingCloseWriter struct {
+	*bytes.Buffer
+}
+
+func (instance *failingCloseWriter) Close() error {
+	return errors.New("close failed")
+}
+
+func Test_UnusedReport_execute_considers_cronjob_references(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	used, unused := newTestSecret("ns1", "backup-credentials", nil), newTestSecret("ns1", "forgotten", nil)
+	client := fake.NewSimpleClientset(&used, &unused)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestCronJob("batch/v1", "ns1", "backup", "backup-credentials"))
+	instance := UnusedReport{Format: ReportFormatJson, Output: File(filepath.Join(dir, "unused.json"))}
+
+	g.Expect(instance.execute(context.Background(), &KubeSecretsExporter{}, client, dynamicClient)).To(BeNil())
+
+	var actual []UnusedSecret
+	content, err := ioutil.ReadFile(filepath.Join(dir, "unused.json"))
+	g.Expect(err).To(BeNil())
+	g.Expect(json.Unmarshal(content, &actual)).To(BeNil())
+	g.Expect(actual).To(HaveLen(1))
+	g.Expect(actual[0].Secret).To(Equal(Identifier("ns1/forgotten")))
+}
@@ -0,0 +1,161 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/echocat/kube-secrets-exporter/kubernetes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+)
+
+var (
+	ResourceSecrets    = Resource{Group: "", Version: "v1", Resource: "secrets"}
+	ResourceConfigMaps = Resource{Group: "", Version: "v1", Resource: "configmaps"}
+)
+
+type Resource schema.GroupVersionResource
+
+func (instance *Resource) Set(plain string) error {
+	switch strings.ToLower(strings.TrimSpace(plain)) {
+	case "", "secret", "secrets":
+		*instance = ResourceSecrets
+		return nil
+	case "configmap", "configmaps":
+		*instance = ResourceConfigMaps
+		return nil
+	}
+	parts := strings.Split(strings.TrimSpace(plain), "/")
+	for _, part := range parts {
+		if part == "" {
+			return fmt.Errorf("illegal resource: %s", plain)
+		}
+	}
+	switch len(parts) {
+	case 2:
+		*instance = Resource{Version: parts[0], Resource: parts[1]}
+	case 3:
+		*instance = Resource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+	default:
+		return fmt.Errorf("illegal resource, expected 'secrets', 'configmaps', '<version>/<resource>' or '<group>/<version>/<resource>': %s", plain)
+	}
+	return nil
+}
+
+func (instance Resource) String() string {
+	switch instance {
+	case ResourceSecrets:
+		return "secrets"
+	case ResourceConfigMaps:
+		return "configmaps"
+	case Resource{}:
+		return ""
+	}
+	if instance.Group == "" {
+		return instance.Version + "/" + instance.Resource
+	}
+	return instance.Group + "/" + instance.Version + "/" + instance.Resource
+}
+
+func (instance Resource) IsSecrets() bool {
+	return instance == ResourceSecrets || instance == Resource{}
+}
+
+type ObjectVisitor func(object runtime.Object, resourceVersion string) error
+
+func (instance *KubeSecretsExporter) VisitObjects(ctx context.Context, env *kubernetes.Environment, visitor ObjectVisitor) error {
+	if instance.Resource.IsSecrets() {
+		return instance.VisitSecrets(ctx, env, func(secret v1.Secret, resourceVersion string) error {
+			return visitor(&secret, resourceVersion)
+		})
+	}
+	if !instance.Selector.Type.IsEmpty() {
+		return fmt.Errorf("--selector.type is only supported for secrets but resource is %v", instance.Resource)
+	}
+
+	if instance.Resource == ResourceConfigMaps {
+		client, err := env.NewClient()
+		if err != nil {
+			return fmt.Errorf("cannot create kubernetes client: %w", err)
+		}
+		configMaps := client.CoreV1().ConfigMaps(env.Namespace)
+		return instance.visitPagesOf(ctx, func(opts metav1.ListOptions) ([]runtime.Object, metav1.ListInterface, error) {
+			resp, err := configMaps.List(ctx, opts)
+			if err != nil {
+				return nil, nil, err
+			}
+			items := make([]runtime.Object, len(resp.Items))
+			for i := range resp.Items {
+				items[i] = &resp.Items[i]
+			}
+			return items, resp, nil
+		}, visitor)
+	}
+
+	client, err := env.NewDynamicClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	resources := client.Resource(schema.GroupVersionResource(instance.Resource)).Namespace(env.Namespace)
+	return instance.visitPagesOf(ctx, func(opts metav1.ListOptions) ([]runtime.Object, metav1.ListInterface, error) {
+		resp, err := resources.List(ctx, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		items := make([]runtime.Object, len(resp.Items))
+		for i := range resp.Items {
+			items[i] = &resp.Items[i]
+		}
+		return items, resp, nil
+	}, visitor)
+}
+
+func (instance *KubeSecretsExporter) visitPagesOf(ctx context.Context, list func(metav1.ListOptions) ([]runtime.Object, metav1.ListInterface, error), visitor ObjectVisitor) error {
+	opts := metav1.ListOptions{
+		Limit: int64(instance.PageSize),
+	}
+	for {
+		items, resp, err := list(opts)
+		if err != nil {
+			return fmt.Errorf("cannot retrieve %v from kubernetes: %w", instance.Resource, err)
+		}
+		for _, item := range items {
+			if err := instance.onObject(item, visitor); err != nil {
+				return fmt.Errorf("cannot handle %v %v: %w", instance.Resource, identifierOfObject(item), err)
+			}
+		}
+		if v := resp.GetContinue(); v != "" {
+			opts.Continue = v
+		} else {
+			return nil
+		}
+	}
+}
+
+func (instance *KubeSecretsExporter) onObject(object runtime.Object, visitor ObjectVisitor) error {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return err
+	}
+	if instance.Selector.MatchesObject(object) {
+		resourceVersion := accessor.GetResourceVersion()
+		if err := instance.Filter.Apply(accessor); err != nil {
+			return err
+		}
+		if err := visitor(object, resourceVersion); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func identifierOfObject(object runtime.Object) Identifier {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return ""
+	}
+	return Identifier(accessor.GetNamespace() + "/" + accessor.GetName())
+}
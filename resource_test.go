@@ -0,0 +1,108 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"context"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"testing"
+)
+
+func Test_Resource_Set(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var instance Resource
+	g.Expect(instance.Set("ConfigMaps")).To(BeNil())
+	g.Expect(instance).To(Equal(ResourceConfigMaps))
+	g.Expect(instance.Set("cert-manager.io/v1/certificates")).To(BeNil())
+	g.Expect(instance).To(Equal(Resource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}))
+	g.Expect(instance.String()).To(Equal("cert-manager.io/v1/certificates"))
+	g.Expect(instance.Set("v1/services")).To(BeNil())
+	g.Expect(instance).To(Equal(Resource{Version: "v1", Resource: "services"}))
+	g.Expect(instance.Set("a//b")).NotTo(BeNil())
+	g.Expect(instance.Set("a/b/c/d")).NotTo(BeNil())
+}
+
+func Test_KubeSecretsExporter_visitPagesOf_selects_and_filters_any_object(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	newCertificate := func(namespace, name string) *unstructured.Unstructured {
+		result := &unstructured.Unstructured{}
+		result.SetAPIVersion("cert-manager.io/v1")
+		result.SetKind("Certificate")
+		result.SetNamespace(namespace)
+		result.SetName(name)
+		result.SetUID("uid")
+		result.SetResourceVersion("42")
+		result.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}", "foo": "bar"})
+		return result
+	}
+	pages := [][]runtime.Object{
+		{newCertificate("ns1", "a"), newCertificate("ns2", "b")},
+		{newCertificate("ns1", "c")},
+	}
+	exporter := &KubeSecretsExporter{
+		Resource: Resource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"},
+		Filter:   Filter{RemoveUid: true, RemoveResourceVersion: true, RemoveKubectlHints: true},
+	}
+	g.Expect(exporter.Selector.Name.Set("ns1/.*")).To(BeNil())
+
+	var visited []*unstructured.Unstructured
+	var resourceVersions []string
+	g.Expect(exporter.visitPagesOf(context.Background(), func(opts metav1.ListOptions) ([]runtime.Object, metav1.ListInterface, error) {
+		list := &unstructured.UnstructuredList{}
+		if opts.Continue == "" {
+			list.SetContinue("next")
+			return pages[0], list, nil
+		}
+		return pages[1], list, nil
+	}, func(object runtime.Object, resourceVersion string) error {
+		visited = append(visited, object.(*unstructured.Unstructured))
+		resourceVersions = append(resourceVersions, resourceVersion)
+		return nil
+	})).To(BeNil())
+
+	g.Expect(visited).To(HaveLen(2))
+	g.Expect(visited[0].GetName()).To(Equal("a"))
+	g.Expect(visited[0].GetUID()).To(BeEmpty())
+	g.Expect(visited[0].GetResourceVersion()).To(BeEmpty())
+	g.Expect(visited[0].GetAnnotations()).To(Equal(map[string]string{"foo": "bar"}))
+	g.Expect(visited[1].GetName()).To(Equal("c"))
+	g.Expect(resourceVersions).To(Equal([]string{"42", "42"}))
+
+	var groupings OutputGroupings
+	g.Expect(groupings.Set("{{.Kind | lower}}/{{.Namespace}}/{{.Name}}.yaml")).To(BeNil())
+	g.Expect(groupings.Apply(newOutputGroupingContext(visited[0], OutputRun{}))).To(Equal(File("certificate/ns1/a.yaml")))
+
+	buf := new(bytes.Buffer)
+	g.Expect((&OutputConsumer{}).encodeGroup("certificates.yaml", []runtime.Object{visited[0]}, buf)).To(BeNil())
+	g.Expect(buf.String()).To(Equal(`apiVersion: v1
+items:
+- apiVersion: cert-manager.io/v1
+  kind: Certificate
+  metadata:
+    annotations:
+      foo: bar
+    name: a
+    namespace: ns1
+kind: List
+metadata: {}
+`))
+}
+
+func Test_KubeSecretsExporter_VisitObjects_rejects_type_selector_for_other_resources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	exporter := &KubeSecretsExporter{
+		Resource: ResourceConfigMaps,
+	}
+	g.Expect(exporter.Selector.Type.Set("Opaque")).To(BeNil())
+
+	err := exporter.VisitObjects(context.Background(), &exporter.Environment, func(runtime.Object, string) error {
+		return nil
+	})
+	g.Expect(err).NotTo(BeNil())
+	g.Expect(err.Error()).To(Equal("--selector.type is only supported for secrets but resource is configmaps"))
+}
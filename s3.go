@@ -0,0 +1,298 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const s3Scheme = "s3://"
+
+type S3 struct {
+	Endpoint             string
+	Region               string
+	PathStyle            bool
+	ServerSideEncryption string
+	KmsKeyId             string
+	Retries              uint
+
+	client *http.Client
+	now    func() time.Time
+}
+
+func (instance *S3) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("s3.").
+		EnvarNamePrefix("S3_")
+
+	g.Flag("endpoint", "Endpoint of the S3 compatible object storage used for 's3://<bucket>/<key>' files;"+
+		" example 'http://localhost:9000'. Empty means AWS S3 of the configured region.").
+		Envar("ENDPOINT").
+		StringVar(&instance.Endpoint)
+	g.Flag("region", "Region of the object storage. Empty means $AWS_REGION, $AWS_DEFAULT_REGION or 'us-east-1'.").
+		Envar("REGION").
+		StringVar(&instance.Region)
+	g.Flag("path-style", "Address buckets as part of the path instead of the host name. This is always the case if an endpoint is set.").
+		Envar("PATH_STYLE").
+		BoolVar(&instance.PathStyle)
+	g.Flag("sse", "Server side encryption which should be requested for uploaded objects. Can be: AES256,aws:kms").
+		Envar("SSE").
+		EnumVar(&instance.ServerSideEncryption, "", "AES256", "aws:kms")
+	g.Flag("sse-kms-key-id", "KMS key to use if server side encryption is 'aws:kms'. Empty means the default key.").
+		Envar("SSE_KMS_KEY_ID").
+		StringVar(&instance.KmsKeyId)
+	g.Flag("retries", "How often a failed upload should be retried.").
+		Default("3").
+		Envar("RETRIES").
+		UintVar(&instance.Retries)
+}
+
+func (instance S3) fingerprint() string {
+	return fmt.Sprintf("%s|%s|%v|%s|%s", instance.Endpoint, instance.Region, instance.PathStyle, instance.ServerSideEncryption, instance.KmsKeyId)
+}
+
+func (instance File) IsS3() bool {
+	return strings.HasPrefix(strings.ToLower(instance.String()), s3Scheme)
+}
+
+func (instance File) s3Location() (bucket, key string, err error) {
+	plain := instance.String()[len(s3Scheme):]
+	i := strings.IndexByte(plain, '/')
+	if i <= 0 || i == len(plain)-1 {
+		return "", "", fmt.Errorf("illegal s3 location '%v', expected 's3://<bucket>/<key>'", instance)
+	}
+	return plain[:i], plain[i+1:], nil
+}
+
+func (instance S3) Open(f File) (io.WriteCloser, error) {
+	bucket, key, err := f.s3Location()
+	if err != nil {
+		return nil, err
+	}
+	return &s3ObjectWriter{
+		s3:     instance,
+		bucket: bucket,
+		key:    key,
+	}, nil
+}
+
+type s3ObjectWriter struct {
+	bytes.Buffer
+	s3     S3
+	bucket string
+	key    string
+	closed bool
+}
+
+func (instance *s3ObjectWriter) Abort() {
+	instance.closed = true
+	instance.Reset()
+}
+
+func (instance *s3ObjectWriter) Close() error {
+	if instance.closed {
+		return nil
+	}
+	instance.closed = true
+	return instance.s3.Put(instance.bucket, instance.key, instance.Bytes())
+}
+
+type s3Credentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+func s3CredentialsFromEnvironment() (s3Credentials, error) {
+	result := s3Credentials{
+		accessKeyId:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if result.accessKeyId == "" || result.secretAccessKey == "" {
+		return result, fmt.Errorf("neither AWS_ACCESS_KEY_ID nor AWS_SECRET_ACCESS_KEY environment variables may be empty")
+	}
+	return result, nil
+}
+
+func (instance S3) region() string {
+	if v := instance.Region; v != "" {
+		return v
+	}
+	if v := os.Getenv("AWS_REGION"); v != "" {
+		return v
+	}
+	if v := os.Getenv("AWS_DEFAULT_REGION"); v != "" {
+		return v
+	}
+	return "us-east-1"
+}
+
+func (instance S3) objectUrl(bucket, key string) (*url.URL, error) {
+	endpoint := instance.Endpoint
+	pathStyle := instance.PathStyle || endpoint != ""
+	if endpoint == "" {
+		endpoint = "https://s3." + instance.region() + ".amazonaws.com"
+	}
+	result, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("illegal s3 endpoint '%s': %w", endpoint, err)
+	}
+	if pathStyle {
+		result.Path = path.Join("/", result.Path, bucket, key)
+	} else {
+		result.Host = bucket + "." + result.Host
+		result.Path = path.Join("/", result.Path, key)
+	}
+	return result, nil
+}
+
+func (instance S3) Put(bucket, key string, content []byte) error {
+	credentials, err := s3CredentialsFromEnvironment()
+	if err != nil {
+		return err
+	}
+	u, err := instance.objectUrl(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := uint(0); attempt <= instance.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * 200 * time.Millisecond)
+		}
+		retryable, err := instance.put(u, content, credentials)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+	}
+	return fmt.Errorf("cannot upload s3://%s/%s: %w", bucket, key, lastErr)
+}
+
+func (instance S3) put(u *url.URL, content []byte, credentials s3Credentials) (retryable bool, err error) {
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = int64(len(content))
+	if ct := mime.TypeByExtension(path.Ext(u.Path)); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	if v := instance.ServerSideEncryption; v != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption", v)
+		if k := instance.KmsKeyId; k != "" && v == "aws:kms" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", k)
+		}
+	}
+	if v := credentials.sessionToken; v != "" {
+		req.Header.Set("X-Amz-Security-Token", v)
+	}
+	now := time.Now
+	if instance.now != nil {
+		now = instance.now
+	}
+	signS3Request(req, content, credentials, instance.region(), now())
+
+	client := instance.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		fmt.Errorf("unexpected response %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+func signS3Request(req *http.Request, payload []byte, credentials s3Credentials, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSha256([]byte("AWS4"+credentials.secretAccessKey), date)
+	key = hmacSha256(key, region)
+	key = hmacSha256(key, "s3")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+credentials.accessKeyId+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func s3EscapePath(plain string) string {
+	result := new(strings.Builder)
+	for _, b := range []byte(plain) {
+		if b == '/' || b == '-' || b == '_' || b == '.' || b == '~' ||
+			(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') {
+			result.WriteByte(b)
+		} else {
+			_, _ = fmt.Fprintf(result, "%%%02X", b)
+		}
+	}
+	return result.String()
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, content string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(content))
+	return h.Sum(nil)
+}
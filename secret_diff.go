@@ -0,0 +1,152 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"sort"
+)
+
+type SecretChange uint8
+
+const (
+	SecretChangeAdded    = SecretChange(0)
+	SecretChangeRemoved  = SecretChange(1)
+	SecretChangeModified = SecretChange(2)
+)
+
+func (instance SecretChange) String() string {
+	if v, ok := secretChangeToName[instance]; ok {
+		return v
+	}
+	return fmt.Sprintf("illegal secret change: %d", instance)
+}
+
+func (instance SecretChange) MarshalText() ([]byte, error) {
+	return []byte(instance.String()), nil
+}
+
+var secretChangeToName = map[SecretChange]string{
+	SecretChangeAdded:    "added",
+	SecretChangeRemoved:  "removed",
+	SecretChangeModified: "modified",
+}
+
+type SecretDifference struct {
+	Secret Identifier      `json:"secret"`
+	Change SecretChange    `json:"change"`
+	Type   *TypeDifference `json:"type,omitempty"`
+	Keys   []KeyDifference `json:"keys,omitempty"`
+}
+
+type TypeDifference struct {
+	Old SecretType `json:"old"`
+	New SecretType `json:"new"`
+}
+
+type KeyDifference struct {
+	Key    string       `json:"key"`
+	Change SecretChange `json:"change"`
+	Old    string       `json:"old,omitempty"`
+	New    string       `json:"new,omitempty"`
+}
+
+type SecretDifferences []SecretDifference
+
+func (instance SecretDifferences) IsEmpty() bool {
+	return len(instance) == 0
+}
+
+func DiffSecrets(old, new map[Identifier]v1.Secret, display ValueDisplay) SecretDifferences {
+	result := SecretDifferences{}
+	for id, o := range old {
+		if n, ok := new[id]; !ok {
+			result = append(result, SecretDifference{
+				Secret: id,
+				Change: SecretChangeRemoved,
+			})
+		} else if diff, modified := diffSecret(id, o, n, display); modified {
+			result = append(result, diff)
+		}
+	}
+	for id := range new {
+		if _, ok := old[id]; !ok {
+			result = append(result, SecretDifference{
+				Secret: id,
+				Change: SecretChangeAdded,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Secret < result[j].Secret
+	})
+	return result
+}
+
+func diffSecret(id Identifier, old, new v1.Secret, display ValueDisplay) (SecretDifference, bool) {
+	result := SecretDifference{
+		Secret: id,
+		Change: SecretChangeModified,
+	}
+	if old.Type != new.Type {
+		result.Type = &TypeDifference{
+			Old: SecretType(old.Type),
+			New: SecretType(new.Type),
+		}
+	}
+
+	oldData, newData := secretDataOf(old), secretDataOf(new)
+	for key, o := range oldData {
+		if n, ok := newData[key]; !ok {
+			result.Keys = append(result.Keys, KeyDifference{
+				Key:    key,
+				Change: SecretChangeRemoved,
+				Old:    display.Display(o),
+			})
+		} else if !bytes.Equal(o, n) {
+			result.Keys = append(result.Keys, KeyDifference{
+				Key:    key,
+				Change: SecretChangeModified,
+				Old:    display.Display(o),
+				New:    display.Display(n),
+			})
+		}
+	}
+	for key, n := range newData {
+		if _, ok := oldData[key]; !ok {
+			result.Keys = append(result.Keys, KeyDifference{
+				Key:    key,
+				Change: SecretChangeAdded,
+				New:    display.Display(n),
+			})
+		}
+	}
+	sort.Slice(result.Keys, func(i, j int) bool {
+		return result.Keys[i].Key < result.Keys[j].Key
+	})
+
+	return result, result.Type != nil || len(result.Keys) > 0
+}
+
+func secretDataOf(secret v1.Secret) map[string][]byte {
+	result := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for k, v := range secret.Data {
+		result[k] = v
+	}
+	for k, v := range secret.StringData {
+		result[k] = []byte(v)
+	}
+	return result
+}
+
+func identifierOf(secret v1.Secret) Identifier {
+	return Identifier(secret.Namespace + "/" + secret.Name)
+}
+
+func secretsByIdentifier(secrets []v1.Secret) map[Identifier]v1.Secret {
+	result := make(map[Identifier]v1.Secret, len(secrets))
+	for _, secret := range secrets {
+		result[identifierOf(secret)] = secret
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_DiffSecrets_without_changes_returns_empty(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	old := secretsByIdentifier([]v1.Secret{newTestSecret("ns1", "a", map[string]string{"k": "v"})})
+	current := secretsByIdentifier([]v1.Secret{newTestSecret("ns1", "a", map[string]string{"k": "v"})})
+
+	actual := DiffSecrets(old, current, ValueDisplayHidden)
+
+	g.Expect(actual.IsEmpty()).To(BeTrue())
+}
+
+func Test_DiffSecrets_reports_added_removed_and_modified(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	old := secretsByIdentifier([]v1.Secret{
+		newTestSecret("ns1", "a", map[string]string{"k1": "v1", "k2": "v2"}),
+		newTestSecret("ns1", "b", nil),
+	})
+	current := secretsByIdentifier([]v1.Secret{
+		newTestSecret("ns1", "a", map[string]string{"k1": "changed", "k3": "v3"}),
+		newTestSecret("ns2", "c", nil),
+	})
+
+	actual := DiffSecrets(old, current, ValueDisplayHidden)
+
+	g.Expect(actual).To(Equal(SecretDifferences{{
+		Secret: "ns1/a",
+		Change: SecretChangeModified,
+		Keys: []KeyDifference{
+			{Key: "k1", Change: SecretChangeModified},
+			{Key: "k2", Change: SecretChangeRemoved},
+			{Key: "k3", Change: SecretChangeAdded},
+		},
+	}, {
+		Secret: "ns1/b",
+		Change: SecretChangeRemoved,
+	}, {
+		Secret: "ns2/c",
+		Change: SecretChangeAdded,
+	}}))
+}
+
+func Test_DiffSecrets_with_hash_display_never_contains_values(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	old := secretsByIdentifier([]v1.Secret{newTestSecret("ns1", "a", map[string]string{"k": "secret1"})})
+	current := secretsByIdentifier([]v1.Secret{newTestSecret("ns1", "a", map[string]string{"k": "secret2"})})
+
+	actual := DiffSecrets(old, current, ValueDisplayHash)
+
+	g.Expect(actual).To(HaveLen(1))
+	g.Expect(actual[0].Keys).To(HaveLen(1))
+	g.Expect(actual[0].Keys[0].Old).To(HavePrefix("sha256:"))
+	g.Expect(actual[0].Keys[0].New).To(HavePrefix("sha256:"))
+	g.Expect(actual[0].Keys[0].Old).NotTo(Equal(actual[0].Keys[0].New))
+	g.Expect(actual[0].Keys[0].Old).NotTo(ContainSubstring("secret1"))
+}
+
+func Test_decodeSecrets_of_separated_yaml_and_list_succeeds(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	actual, err := decodeSecrets(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: a
+  namespace: ns1
+data:
+  k: dg==
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: b
+    namespace: ns2
+`))
+
+	g.Expect(err).To(BeNil())
+	g.Expect(actual).To(HaveLen(2))
+	g.Expect(identifierOf(actual[0])).To(Equal(Identifier("ns1/a")))
+	g.Expect(actual[0].Data).To(Equal(map[string][]byte{"k": []byte("v")}))
+	g.Expect(identifierOf(actual[1])).To(Equal(Identifier("ns2/b")))
+}
+
+func Test_SecretSource_Load_of_directory_ignores_foreign_files(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	files := map[string]string{
+		"ns1.yaml":           "apiVersion: v1\nkind: Secret\nmetadata:\n  name: a\n  namespace: ns1\n",
+		"kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n- ns1.yaml\n",
+		"state.json":         `{"output": "file=ns1.yaml", "secrets": {"ns1/a": {"resourceVersion": "1"}}}`,
+		"manifest.json":      `["ns1.yaml"]`,
+		filepath.Join("templates", "ns1-secrets.yaml"): "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: a\ndata:\n  \"k\": {{ index .Values \"secrets\" \"ns1\" \"a\" \"k\" | b64enc | quote }}\n",
+	}
+	for name, content := range files {
+		g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)).To(BeNil())
+		g.Expect(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)).To(BeNil())
+	}
+
+	actual, err := SecretSource(dir).Load()
+
+	g.Expect(err).To(BeNil())
+	g.Expect(actual).To(HaveLen(1))
+	g.Expect(identifierOf(actual[0])).To(Equal(Identifier("ns1/a")))
+}
+
+func newTestSecret(namespace, name string, data map[string]string) v1.Secret {
+	result := v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Type: v1.SecretTypeOpaque,
+	}
+	if data != nil {
+		result.Data = make(map[string][]byte, len(data))
+		for k, v := range data {
+			result.Data[k] = []byte(v)
+		}
+	}
+	return result
+}
+
+func Test_Diff_of_exported_files_without_changes_succeeds(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	a, b := newTestSecret("ns1", "a", map[string]string{"k": "v"}), newTestSecret("ns2", "b", nil)
+	client := fake.NewSimpleClientset(&a, &b)
+
+	for _, file := range []string{"{{.Namespace}}.yaml", "{{.Namespace}}.json", "all.ndjson", "all.yml"} {
+		dir := newTestDirectory(g)
+		exporter := &KubeSecretsExporter{}
+		consumer := &OutputConsumer{}
+		consumer.Format = OutputFormatAuto
+		g.Expect(consumer.File.Set(filepath.Join(dir, file))).To(BeNil())
+		_, err := exporter.visitSecretsOf(context.Background(), client.CoreV1().Secrets(""), func(secret v1.Secret, _ string) error {
+			_, err := consumer.Consume(&secret)
+			return err
+		})
+		g.Expect(err).To(BeNil())
+		g.Expect(consumer.Finalize()).To(BeNil())
+
+		instance := Diff{Source: SecretSource(dir), Output: File(filepath.Join(dir, "report.txt"))}
+		g.Expect(instance.execute(exporter, client.CoreV1().Secrets(""))).To(BeNil(), file)
+
+		_ = os.RemoveAll(dir)
+	}
+}
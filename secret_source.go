@@ -0,0 +1,162 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var secretGroupVersionKind = v1.SchemeGroupVersion.WithKind("Secret")
+
+var secretSourceExtensions = map[string]bool{
+	".yaml":   true,
+	".yml":    true,
+	".json":   true,
+	".ndjson": true,
+}
+
+type SecretSource string
+
+func (instance *SecretSource) Set(plain string) error {
+	if plain == "" {
+		*instance = ""
+		return nil
+	}
+	if _, err := os.Stat(plain); err != nil {
+		return fmt.Errorf("illegal secret source: %w", err)
+	}
+	*instance = SecretSource(plain)
+	return nil
+}
+
+func (instance SecretSource) String() string {
+	return string(instance)
+}
+
+func (instance SecretSource) Load() ([]v1.Secret, error) {
+	fi, err := os.Stat(instance.String())
+	if err != nil {
+		return nil, fmt.Errorf("cannot access secret source '%v': %w", instance, err)
+	}
+	if !fi.IsDir() {
+		return loadSecretsFromFile(instance.String())
+	}
+
+	var result []v1.Secret
+	if err := filepath.Walk(instance.String(), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !secretSourceExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("cannot read '%s': %w", path, err)
+		}
+		secrets, err := decodeSecrets(bytes.NewReader(content))
+		if err != nil {
+			log.Printf("ignored '%s' of secret source because it does not contain kubernetes objects: %v", path, err)
+			return nil
+		}
+		result = append(result, secrets...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func loadSecretsFromFile(path string) ([]v1.Secret, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open '%s': %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	result, err := decodeSecrets(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read '%s': %w", path, err)
+	}
+	return result, nil
+}
+
+func decodeSecrets(r io.Reader) ([]v1.Secret, error) {
+	var result []v1.Secret
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+			continue
+		}
+		secrets, err := decodeSecretsOf(raw)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, secrets...)
+	}
+}
+
+func decodeSecretsOf(raw []byte) ([]v1.Secret, error) {
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, nil
+	}
+	var header struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, err
+	}
+	switch header.Kind {
+	case "Secret", "List":
+	case "":
+		if header.Metadata.Name == "" {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw, &secretGroupVersionKind, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch v := obj.(type) {
+	case *v1.Secret:
+		return []v1.Secret{*v}, nil
+	case *v1.List:
+		return decodeSecretsOfList(v.Items)
+	default:
+		return nil, nil
+	}
+}
+
+func decodeSecretsOfList(items []runtime.RawExtension) ([]v1.Secret, error) {
+	var result []v1.Secret
+	for _, item := range items {
+		secrets, err := decodeSecretsOf(item.Raw)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, secrets...)
+	}
+	return result, nil
+}
@@ -92,6 +92,10 @@ func (instance SecretTypeMatcher) Matches(candidate SecretType) bool {
 	return true
 }
 
+func (instance SecretTypeMatcher) IsEmpty() bool {
+	return len(instance.Includes) == 0 && len(instance.Excludes) == 0
+}
+
 func (instance *SecretTypeMatcher) Set(plain string) error {
 	var result SecretTypeMatcher
 	for _, part := range strings.Split(plain, ",") {
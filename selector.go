@@ -3,6 +3,7 @@ package kube_secrets_exporter
 import (
 	"github.com/blaubaer/kingpin"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 type Selector struct {
@@ -14,16 +15,23 @@ func (instance *Selector) RegisterFlags(fg kingpin.FlagGroup) {
 	g := fg.FlagGroup("selector.").
 		EnvarNamePrefix("SELECTOR_")
 
-	g.Flag("type", "Which types should be exported. Empty means all.").
+	g.Flag("type", "Which types of secrets should be exported. Empty means all. Only supported if --resource is secrets.").
 		Envar("TYPE").
 		HintAction(AllSecretTypes.Strings).
 		SetValue(&instance.Type)
-	g.Flag("name", "Which names should be exported. This has to match '<namespace>/<name>' of the secret. Empty means all.").
+	g.Flag("name", "Which names should be exported. This has to match '<namespace>/<name>' of the resource. Empty means all.").
 		Envar("NAME").
 		SetValue(&instance.Name)
 }
 
 func (instance Selector) Matches(secret v1.Secret) bool {
 	return instance.Type.Matches(SecretType(secret.Type)) &&
-		instance.Name.Matches(Identifier(secret.Namespace+"/"+secret.Name))
+		instance.Name.Matches(identifierOf(secret))
+}
+
+func (instance Selector) MatchesObject(object runtime.Object) bool {
+	if secret, ok := object.(*v1.Secret); ok {
+		return instance.Matches(*secret)
+	}
+	return instance.Name.Matches(identifierOfObject(object))
 }
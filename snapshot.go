@@ -0,0 +1,194 @@
+package kube_secrets_exporter
+
+import (
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	snapshotLayout = "20060102T150405Z"
+	snapshotLatest = "latest"
+)
+
+type Snapshot struct {
+	Root       string
+	KeepLast   uint
+	KeepDaily  uint
+	KeepWeekly uint
+
+	now func() time.Time
+}
+
+func (instance *Snapshot) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("snapshot.").
+		EnvarNamePrefix("SNAPSHOT_")
+
+	g.Flag("root", "If set every run is written into a new timestamped directory below this root"+
+		" and a '"+snapshotLatest+"' symlink points to it. The directory is available as '{{.Snapshot}}'"+
+		" inside of --output.file; example '{{.Snapshot}}/{{.Namespace}}.yaml'. Empty means no snapshots.").
+		Envar("ROOT").
+		StringVar(&instance.Root)
+	g.Flag("keep-last", "How many of the most recent snapshots should be kept. 0 means not limited by this rule.").
+		Envar("KEEP_LAST").
+		UintVar(&instance.KeepLast)
+	g.Flag("keep-daily", "For how many days the newest snapshot of each day should be kept. 0 means not limited by this rule.").
+		Envar("KEEP_DAILY").
+		UintVar(&instance.KeepDaily)
+	g.Flag("keep-weekly", "For how many weeks the newest snapshot of each week should be kept. 0 means not limited by this rule.").
+		Envar("KEEP_WEEKLY").
+		UintVar(&instance.KeepWeekly)
+}
+
+func (instance Snapshot) IsEnabled() bool {
+	return instance.Root != ""
+}
+
+func (instance Snapshot) hasRetention() bool {
+	return instance.KeepLast > 0 || instance.KeepDaily > 0 || instance.KeepWeekly > 0
+}
+
+func (instance Snapshot) currentTime() time.Time {
+	if now := instance.now; now != nil {
+		return now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (instance Snapshot) Begin() (*SnapshotRun, error) {
+	if !instance.IsEnabled() {
+		return &SnapshotRun{}, nil
+	}
+	if err := os.MkdirAll(instance.Root, 0755); err != nil {
+		return nil, fmt.Errorf("cannot ensure snapshot root '%s': %w", instance.Root, err)
+	}
+	created := instance.currentTime()
+	name := created.Format(snapshotLayout)
+	dir := filepath.Join(instance.Root, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create snapshot '%s': %w", dir, err)
+	}
+	return &SnapshotRun{
+		Snapshot:  instance,
+		Directory: dir,
+		created:   created,
+	}, nil
+}
+
+type SnapshotRun struct {
+	Snapshot
+	Directory string
+
+	created time.Time
+}
+
+func (instance *SnapshotRun) Abort() {
+	if instance.Directory != "" {
+		_ = os.RemoveAll(instance.Directory)
+	}
+}
+
+func (instance *SnapshotRun) Commit() error {
+	if instance.Directory == "" {
+		return nil
+	}
+	if err := instance.link(); err != nil {
+		return err
+	}
+	return instance.applyRetention()
+}
+
+func (instance *SnapshotRun) link() error {
+	latest := filepath.Join(instance.Root, snapshotLatest)
+	tmp := latest + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(instance.Directory), tmp); err != nil {
+		return fmt.Errorf("cannot link '%s' to '%s': %w", latest, instance.Directory, err)
+	}
+	if err := os.Rename(tmp, latest); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("cannot link '%s' to '%s': %w", latest, instance.Directory, err)
+	}
+	return nil
+}
+
+func (instance *SnapshotRun) applyRetention() error {
+	if !instance.hasRetention() {
+		return nil
+	}
+	infos, err := ioutil.ReadDir(instance.Root)
+	if err != nil {
+		return fmt.Errorf("cannot list snapshots of '%s': %w", instance.Root, err)
+	}
+	var candidates []time.Time
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		if t, err := time.Parse(snapshotLayout, info.Name()); err == nil {
+			candidates = append(candidates, t)
+		}
+	}
+
+	keep := instance.snapshotsToKeep(candidates, instance.created)
+	for _, candidate := range candidates {
+		if keep[candidate] || candidate.Equal(instance.created) {
+			continue
+		}
+		dir := filepath.Join(instance.Root, candidate.Format(snapshotLayout))
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("cannot remove expired snapshot '%s': %w", dir, err)
+		}
+	}
+	return nil
+}
+
+func (instance Snapshot) snapshotsToKeep(candidates []time.Time, now time.Time) map[time.Time]bool {
+	sorted := make([]time.Time, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].After(sorted[j])
+	})
+
+	result := make(map[time.Time]bool)
+	for i, candidate := range sorted {
+		if uint(i) < instance.KeepLast {
+			result[candidate] = true
+		}
+	}
+
+	keepPeriodically := func(count uint, period func(time.Time) time.Time, length time.Duration) {
+		if count == 0 {
+			return
+		}
+		oldest := period(now).Add(-time.Duration(count-1) * length)
+		seen := make(map[time.Time]bool)
+		for _, candidate := range sorted {
+			p := period(candidate)
+			if p.Before(oldest) || seen[p] {
+				continue
+			}
+			seen[p] = true
+			result[candidate] = true
+		}
+	}
+	keepPeriodically(instance.KeepDaily, startOfDay, 24*time.Hour)
+	keepPeriodically(instance.KeepWeekly, startOfWeek, 7*24*time.Hour)
+
+	return result
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,72 @@
+package kube_secrets_exporter
+
+import (
+	. "github.com/onsi/gomega"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Snapshot_snapshotsToKeep_combines_rules(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	now := time.Date(2020, 10, 21, 12, 0, 0, 0, time.UTC)
+	candidates := []time.Time{
+		now,
+		now.Add(-1 * time.Hour),
+		now.Add(-2 * time.Hour),
+		now.AddDate(0, 0, -1),
+		now.AddDate(0, 0, -1).Add(-1 * time.Hour),
+		now.AddDate(0, 0, -5),
+		now.AddDate(0, 0, -20),
+	}
+	instance := Snapshot{KeepLast: 2, KeepDaily: 2, KeepWeekly: 2}
+
+	actual := instance.snapshotsToKeep(candidates, now)
+
+	g.Expect(actual).To(Equal(map[time.Time]bool{
+		candidates[0]: true,
+		candidates[1]: true,
+		candidates[3]: true,
+		candidates[5]: true,
+	}))
+}
+
+func Test_Snapshot_run_links_latest_and_applies_retention(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	now := time.Date(2020, 10, 21, 12, 0, 0, 0, time.UTC)
+	instance := Snapshot{Root: dir, KeepLast: 1, now: func() time.Time { return now }}
+
+	first, err := instance.Begin()
+	g.Expect(err).To(BeNil())
+	g.Expect(first.Commit()).To(BeNil())
+
+	now = now.Add(time.Hour)
+	second, err := instance.Begin()
+	g.Expect(err).To(BeNil())
+	g.Expect(second.Commit()).To(BeNil())
+
+	g.Expect(first.Directory).NotTo(BeADirectory())
+	g.Expect(second.Directory).To(BeADirectory())
+	target, err := os.Readlink(filepath.Join(dir, snapshotLatest))
+	g.Expect(err).To(BeNil())
+	g.Expect(target).To(Equal(filepath.Base(second.Directory)))
+}
+
+func Test_KubeSecretsExporter_Export_with_snapshot_requires_snapshot_in_output(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	instance := &KubeSecretsExporter{}
+	instance.Snapshot.Root = filepath.Join(dir, "snapshots")
+	g.Expect(instance.Output.File.Set(filepath.Join(dir, "{{.Namespace}}.yaml"))).To(BeNil())
+
+	err := instance.Export()
+	g.Expect(err).To(MatchError("--snapshot.root requires '{{.Snapshot}}' inside of --output.file or the grouping rules"))
+	g.Expect(instance.Snapshot.Root).NotTo(BeADirectory())
+}
@@ -0,0 +1,270 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+var templateFunctions = template.FuncMap{
+	"b64enc": func(in interface{}) string {
+		return base64.StdEncoding.EncodeToString(templateBytesOf(in))
+	},
+	"b64dec": func(in string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(in)
+		return string(b), err
+	},
+	"toYaml": func(in interface{}) (string, error) {
+		b, err := yaml.Marshal(in)
+		return strings.TrimSuffix(string(b), "\n"), err
+	},
+	"toJson": func(in interface{}) (string, error) {
+		b, err := json.Marshal(in)
+		return string(b), err
+	},
+	"indent": func(spaces int, in string) string {
+		padding := strings.Repeat(" ", spaces)
+		return padding + strings.Replace(in, "\n", "\n"+padding, -1)
+	},
+	"sha256": func(in interface{}) string {
+		sum := sha256.Sum256(templateBytesOf(in))
+		return hex.EncodeToString(sum[:])
+	},
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimPrefix": func(prefix, in string) string { return strings.TrimPrefix(in, prefix) },
+	"trimSuffix": func(suffix, in string) string { return strings.TrimSuffix(in, suffix) },
+	"replace":    func(old, new, in string) string { return strings.Replace(in, old, new, -1) },
+	"default": func(def interface{}, in ...interface{}) interface{} {
+		if len(in) == 0 || in[0] == nil || fmt.Sprint(in[0]) == "" {
+			return def
+		}
+		return in[0]
+	},
+	"date": func(layout string, in time.Time) string {
+		return in.Format(layout)
+	},
+	"safePath": func(in string) string {
+		result := templateUnsafePathCharacters.ReplaceAllString(in, "_")
+		if result == "" || result == "." || result == ".." {
+			return "_"
+		}
+		return result
+	},
+}
+
+var templateUnsafePathCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+type templateMetadataProvider interface {
+	templateLabel(name string) string
+	templateAnnotation(name string) string
+}
+
+type templateScope struct {
+	data  interface{}
+	mutex sync.Mutex
+}
+
+func (instance *templateScope) functions() template.FuncMap {
+	return template.FuncMap{
+		"label": func(name string) (string, error) {
+			if provider, ok := instance.data.(templateMetadataProvider); ok {
+				return provider.templateLabel(name), nil
+			}
+			return "", fmt.Errorf("label is not available in this template")
+		},
+		"annotation": func(name string) (string, error) {
+			if provider, ok := instance.data.(templateMetadataProvider); ok {
+				return provider.templateAnnotation(name), nil
+			}
+			return "", fmt.Errorf("annotation is not available in this template")
+		},
+	}
+}
+
+type scopedTemplate struct {
+	*template.Template
+	scope *templateScope
+}
+
+func parseTemplate(name, plain string) (*scopedTemplate, error) {
+	scope := &templateScope{}
+	t, err := template.New(name).Funcs(templateFunctions).Funcs(scope.functions()).Parse(plain)
+	if err != nil {
+		return nil, err
+	}
+	return &scopedTemplate{t, scope}, nil
+}
+
+func executeTemplate(t *scopedTemplate, w io.Writer, data interface{}) error {
+	t.scope.mutex.Lock()
+	defer t.scope.mutex.Unlock()
+	t.scope.data = data
+	defer func() { t.scope.data = nil }()
+	return t.Execute(w, data)
+}
+
+func templateReferencesField(t *scopedTemplate, name string) bool {
+	if t == nil || t.Tree == nil {
+		return false
+	}
+	return templateNodeReferencesField(t.Tree.Root, name)
+}
+
+func templateNodeReferencesField(node parse.Node, name string) bool {
+	switch v := node.(type) {
+	case *parse.ListNode:
+		if v == nil {
+			return false
+		}
+		for _, child := range v.Nodes {
+			if templateNodeReferencesField(child, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return templateNodeReferencesField(v.Pipe, name)
+	case *parse.PipeNode:
+		if v == nil {
+			return false
+		}
+		for _, cmd := range v.Cmds {
+			if templateNodeReferencesField(cmd, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range v.Args {
+			if templateNodeReferencesField(arg, name) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return len(v.Ident) > 0 && v.Ident[0] == name
+	case *parse.VariableNode:
+		return len(v.Ident) > 1 && v.Ident[0] == "$" && v.Ident[1] == name
+	case *parse.ChainNode:
+		return templateNodeReferencesField(v.Node, name)
+	case *parse.IfNode:
+		return templateNodeReferencesField(&v.BranchNode, name)
+	case *parse.RangeNode:
+		return templateNodeReferencesField(&v.BranchNode, name)
+	case *parse.WithNode:
+		return templateNodeReferencesField(&v.BranchNode, name)
+	case *parse.BranchNode:
+		return templateNodeReferencesField(v.Pipe, name) ||
+			templateNodeReferencesField(v.List, name) ||
+			templateNodeReferencesField(v.ElseList, name)
+	case *parse.TemplateNode:
+		return templateNodeReferencesField(v.Pipe, name)
+	}
+	return false
+}
+
+func templateBytesOf(in interface{}) []byte {
+	switch v := in.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+type Template struct {
+	template *scopedTemplate
+	plain    string
+}
+
+func (instance *Template) Set(plain string) error {
+	if strings.TrimSpace(plain) == "" {
+		*instance = Template{}
+		return nil
+	}
+	t, err := parseTemplate("template", plain)
+	if err != nil {
+		return fmt.Errorf("illegal template: %w", err)
+	}
+	*instance = Template{
+		template: t,
+		plain:    plain,
+	}
+	return nil
+}
+
+func (instance Template) String() string {
+	return instance.plain
+}
+
+func (instance Template) IsEmpty() bool {
+	return instance.template == nil
+}
+
+func (instance Template) Execute(data interface{}) (string, error) {
+	t := instance.template
+	if t == nil {
+		return "", nil
+	}
+	buf := new(bytes.Buffer)
+	if err := executeTemplate(t, buf, data); err != nil {
+		return "", fmt.Errorf("cannot evaluate template %s: %w", instance.plain, err)
+	}
+	return buf.String(), nil
+}
+
+type TemplateFile struct {
+	template *scopedTemplate
+	file     string
+}
+
+func (instance *TemplateFile) Set(plain string) error {
+	if strings.TrimSpace(plain) == "" {
+		*instance = TemplateFile{}
+		return nil
+	}
+	b, err := ioutil.ReadFile(plain)
+	if err != nil {
+		return fmt.Errorf("cannot read template file: %w", err)
+	}
+	t, err := parseTemplate(plain, string(b))
+	if err != nil {
+		return fmt.Errorf("illegal template file %s: %w", plain, err)
+	}
+	*instance = TemplateFile{
+		template: t,
+		file:     plain,
+	}
+	return nil
+}
+
+func (instance TemplateFile) String() string {
+	return instance.file
+}
+
+func (instance TemplateFile) IsEmpty() bool {
+	return instance.template == nil
+}
+
+func (instance TemplateFile) Execute(w io.Writer, data interface{}) error {
+	t := instance.template
+	if t == nil {
+		return fmt.Errorf("no template file configured")
+	}
+	if err := executeTemplate(t, w, data); err != nil {
+		return fmt.Errorf("cannot evaluate template file %s: %w", instance.file, err)
+	}
+	return nil
+}
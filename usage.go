@@ -0,0 +1,354 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	SecretReferenceViaEnv             = "env"
+	SecretReferenceViaEnvFrom         = "envFrom"
+	SecretReferenceViaVolume          = "volume"
+	SecretReferenceViaImagePullSecret = "imagePullSecret"
+	SecretReferenceViaServiceAccount  = "serviceAccount"
+	SecretReferenceViaIngressTls      = "ingressTls"
+)
+
+type Usage struct {
+	Annotate   bool
+	Annotation string
+	Report     File
+	Format     ReportFormat
+}
+
+func (instance *Usage) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("usage.").
+		EnvarNamePrefix("USAGE_")
+
+	g.Flag("annotate", "If set each exported secret is annotated with the Pods, Deployments, StatefulSets, DaemonSets,"+
+		" CronJobs, ServiceAccounts and Ingresses which are referencing it.").
+		Envar("ANNOTATE").
+		BoolVar(&instance.Annotate)
+	g.Flag("annotation", "Name of the annotation containing the references if --usage.annotate is set.").
+		Default("kube-secrets-exporter.echocat.org/used-by").
+		Envar("ANNOTATION").
+		StringVar(&instance.Annotation)
+	g.Flag("report", "If set a report which lists the references of each exported secret is written to this file."+
+		" It can be a regular file, 'stdout' or 'stderr'. Empty means no report.").
+		Envar("REPORT").
+		SetValue(&instance.Report)
+	g.Flag("format", fmt.Sprintf("Which format should be used for the report. Can be: %v", AllReportFormats.String())).
+		Default(ReportFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+}
+
+func (instance Usage) IsEnabled() bool {
+	return instance.Annotate || instance.Report != ""
+}
+
+type SecretReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Via  string `json:"via"`
+}
+
+func (instance SecretReference) String() string {
+	return instance.Kind + "/" + instance.Name
+}
+
+type SecretUsage struct {
+	Secret Identifier        `json:"secret"`
+	UsedBy []SecretReference `json:"usedBy"`
+}
+
+type SecretUsages map[Identifier][]SecretReference
+
+func (instance SecretUsages) add(namespace, secret, kind, name, via string) {
+	id := Identifier(namespace + "/" + secret)
+	reference := SecretReference{Kind: kind, Name: name, Via: via}
+	for _, existing := range instance[id] {
+		if existing == reference {
+			return
+		}
+	}
+	instance[id] = append(instance[id], reference)
+}
+
+func (instance SecretUsages) addPodSpec(namespace, kind, name string, spec v1.PodSpec) {
+	var containers []v1.Container
+	containers = append(containers, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, container := range spec.EphemeralContainers {
+		containers = append(containers, v1.Container{Env: container.Env, EnvFrom: container.EnvFrom})
+	}
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if from := env.ValueFrom; from != nil && from.SecretKeyRef != nil {
+				instance.add(namespace, from.SecretKeyRef.Name, kind, name, SecretReferenceViaEnv)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if ref := envFrom.SecretRef; ref != nil {
+				instance.add(namespace, ref.Name, kind, name, SecretReferenceViaEnvFrom)
+			}
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if source := volume.Secret; source != nil {
+			instance.add(namespace, source.SecretName, kind, name, SecretReferenceViaVolume)
+		}
+		if projected := volume.Projected; projected != nil {
+			for _, source := range projected.Sources {
+				if source.Secret != nil {
+					instance.add(namespace, source.Secret.Name, kind, name, SecretReferenceViaVolume)
+				}
+			}
+		}
+	}
+	for _, ref := range spec.ImagePullSecrets {
+		instance.add(namespace, ref.Name, kind, name, SecretReferenceViaImagePullSecret)
+	}
+}
+
+func (instance SecretUsages) Annotate(secret *v1.Secret, annotation string) {
+	references := instance[identifierOf(*secret)]
+	plains := make([]string, 0, len(references))
+	seen := make(map[string]bool)
+	for _, reference := range references {
+		if plain := reference.String(); !seen[plain] {
+			seen[plain] = true
+			plains = append(plains, plain)
+		}
+	}
+	sort.Strings(plains)
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[annotation] = strings.Join(plains, ",")
+}
+
+func (instance SecretUsages) Of(ids []Identifier) []SecretUsage {
+	sorted := make([]Identifier, len(ids))
+	copy(sorted, ids)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	result := make([]SecretUsage, len(sorted))
+	for i, id := range sorted {
+		references := make([]SecretReference, len(instance[id]))
+		copy(references, instance[id])
+		sort.Slice(references, func(i, j int) bool {
+			if references[i].Kind != references[j].Kind {
+				return references[i].Kind < references[j].Kind
+			}
+			if references[i].Name != references[j].Name {
+				return references[i].Name < references[j].Name
+			}
+			return references[i].Via < references[j].Via
+		})
+		result[i] = SecretUsage{Secret: id, UsedBy: references}
+	}
+	return result
+}
+
+func (instance Usage) Collect(ctx context.Context, exporter *KubeSecretsExporter) (SecretUsages, error) {
+	if !instance.IsEnabled() {
+		return nil, nil
+	}
+	if err := exporter.requireSecrets("usage enrichment"); err != nil {
+		return nil, err
+	}
+	client, err := exporter.Environment.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	dynamicClient, err := exporter.Environment.NewDynamicClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	return collectSecretUsages(ctx, client, dynamicClient, exporter.Environment.Namespace, int64(exporter.PageSize))
+}
+
+var cronJobVersions = []string{"v1", "v1beta1"}
+
+func collectSecretUsages(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, pageSize int64) (SecretUsages, error) {
+	result := make(SecretUsages)
+
+	if err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		pods, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			result.addPodSpec(pod.Namespace, "Pod", pod.Name, pod.Spec)
+		}
+		return pods, nil
+	}); err != nil {
+		return nil, fmt.Errorf("cannot retrieve pods from kubernetes: %w", err)
+	}
+
+	if err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		deployments, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, deployment := range deployments.Items {
+			result.addPodSpec(deployment.Namespace, "Deployment", deployment.Name, deployment.Spec.Template.Spec)
+		}
+		return deployments, nil
+	}); err != nil {
+		return nil, fmt.Errorf("cannot retrieve deployments from kubernetes: %w", err)
+	}
+
+	if err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, statefulSet := range statefulSets.Items {
+			result.addPodSpec(statefulSet.Namespace, "StatefulSet", statefulSet.Name, statefulSet.Spec.Template.Spec)
+		}
+		return statefulSets, nil
+	}); err != nil {
+		return nil, fmt.Errorf("cannot retrieve statefulsets from kubernetes: %w", err)
+	}
+
+	if err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, daemonSet := range daemonSets.Items {
+			result.addPodSpec(daemonSet.Namespace, "DaemonSet", daemonSet.Name, daemonSet.Spec.Template.Spec)
+		}
+		return daemonSets, nil
+	}); err != nil {
+		return nil, fmt.Errorf("cannot retrieve daemonsets from kubernetes: %w", err)
+	}
+
+	for _, version := range cronJobVersions {
+		cronJobs := dynamicClient.Resource(schema.GroupVersionResource{Group: "batch", Version: version, Resource: "cronjobs"}).Namespace(namespace)
+		err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+			list, err := cronJobs.List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				var cronJob batchv1beta1.CronJob
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &cronJob); err != nil {
+					return nil, fmt.Errorf("illegal cronjob %s/%s: %w", item.GetNamespace(), item.GetName(), err)
+				}
+				result.addPodSpec(cronJob.Namespace, "CronJob", cronJob.Name, cronJob.Spec.JobTemplate.Spec.Template.Spec)
+			}
+			return list, nil
+		})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot retrieve cronjobs from kubernetes: %w", err)
+		}
+		break
+	}
+
+	if err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		serviceAccounts, err := client.CoreV1().ServiceAccounts(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, serviceAccount := range serviceAccounts.Items {
+			for _, ref := range serviceAccount.Secrets {
+				ns := ref.Namespace
+				if ns == "" {
+					ns = serviceAccount.Namespace
+				}
+				result.add(ns, ref.Name, "ServiceAccount", serviceAccount.Name, SecretReferenceViaServiceAccount)
+			}
+			for _, ref := range serviceAccount.ImagePullSecrets {
+				result.add(serviceAccount.Namespace, ref.Name, "ServiceAccount", serviceAccount.Name, SecretReferenceViaImagePullSecret)
+			}
+		}
+		return serviceAccounts, nil
+	}); err != nil {
+		return nil, fmt.Errorf("cannot retrieve serviceaccounts from kubernetes: %w", err)
+	}
+
+	if err := listPagesOf(pageSize, func(opts metav1.ListOptions) (metav1.ListInterface, error) {
+		ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, ingress := range ingresses.Items {
+			for _, tls := range ingress.Spec.TLS {
+				if tls.SecretName != "" {
+					result.add(ingress.Namespace, tls.SecretName, "Ingress", ingress.Name, SecretReferenceViaIngressTls)
+				}
+			}
+		}
+		return ingresses, nil
+	}); err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("cannot retrieve ingresses from kubernetes: %w", err)
+	}
+
+	return result, nil
+}
+
+func listPagesOf(pageSize int64, list func(metav1.ListOptions) (metav1.ListInterface, error)) error {
+	opts := metav1.ListOptions{
+		Limit: pageSize,
+	}
+	for {
+		resp, err := list(opts)
+		if err != nil {
+			return err
+		}
+		if v := resp.GetContinue(); v != "" {
+			opts.Continue = v
+		} else {
+			return nil
+		}
+	}
+}
+
+func (instance Usage) WriteReport(open outputSink, usages []SecretUsage) error {
+	if instance.Report == "" {
+		return nil
+	}
+	return writeReport(open, instance.Report, instance.Format, func(w io.Writer) error {
+		return writeSecretUsagesTable(usages, w)
+	}, usages)
+}
+
+func writeSecretUsagesTable(usages []SecretUsage, to io.Writer) error {
+	w := tabwriter.NewWriter(to, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SECRET\tUSED BY\tVIA\n"); err != nil {
+		return err
+	}
+	for _, usage := range usages {
+		if len(usage.UsedBy) == 0 {
+			if _, err := fmt.Fprintf(w, "%v\t<none>\t\n", usage.Secret); err != nil {
+				return err
+			}
+		}
+		for _, reference := range usage.UsedBy {
+			if _, err := fmt.Fprintf(w, "%v\t%v\t%s\n", usage.Secret, reference, reference.Via); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,130 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"context"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func Test_collectSecretUsages_finds_all_references(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	podSpec := v1.PodSpec{
+		Containers: []v1.Container{{
+			Env: []v1.EnvVar{{Name: "PASSWORD", ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "db"}, Key: "password"},
+			}}},
+			EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "env"}}}},
+		}},
+		Volumes: []v1.Volume{
+			{Name: "tls", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "tls"}}},
+			{Name: "projected", VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+				{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "db"}}},
+			}}}},
+		},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+	}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: "ns1", Name: name}
+	}
+	client := fake.NewSimpleClientset(
+		&v1.Pod{ObjectMeta: meta("web-1"), Spec: podSpec},
+		&appsv1.Deployment{ObjectMeta: meta("web"), Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: podSpec}}},
+		&appsv1.StatefulSet{ObjectMeta: meta("db"), Spec: appsv1.StatefulSetSpec{Template: v1.PodTemplateSpec{Spec: podSpec}}},
+		&appsv1.DaemonSet{ObjectMeta: meta("agent"), Spec: appsv1.DaemonSetSpec{Template: v1.PodTemplateSpec{Spec: podSpec}}},
+		&v1.ServiceAccount{ObjectMeta: meta("default"),
+			Secrets:          []v1.ObjectReference{{Name: "default-token"}},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+		},
+	)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestCronJob("batch/v1", "ns1", "backup", "backup-credentials"))
+
+	actual, err := collectSecretUsages(context.Background(), client, dynamicClient, "", 2)
+	g.Expect(err).To(BeNil())
+
+	g.Expect(actual["ns1/db"]).To(ConsistOf(
+		SecretReference{Kind: "Pod", Name: "web-1", Via: SecretReferenceViaEnv},
+		SecretReference{Kind: "Pod", Name: "web-1", Via: SecretReferenceViaVolume},
+		SecretReference{Kind: "Deployment", Name: "web", Via: SecretReferenceViaEnv},
+		SecretReference{Kind: "Deployment", Name: "web", Via: SecretReferenceViaVolume},
+		SecretReference{Kind: "StatefulSet", Name: "db", Via: SecretReferenceViaEnv},
+		SecretReference{Kind: "StatefulSet", Name: "db", Via: SecretReferenceViaVolume},
+		SecretReference{Kind: "DaemonSet", Name: "agent", Via: SecretReferenceViaEnv},
+		SecretReference{Kind: "DaemonSet", Name: "agent", Via: SecretReferenceViaVolume},
+	))
+	g.Expect(actual["ns1/env"]).To(ContainElement(SecretReference{Kind: "Pod", Name: "web-1", Via: SecretReferenceViaEnvFrom}))
+	g.Expect(actual["ns1/tls"]).To(HaveLen(4))
+	g.Expect(actual["ns1/registry"]).To(ContainElement(SecretReference{Kind: "ServiceAccount", Name: "default", Via: SecretReferenceViaImagePullSecret}))
+	g.Expect(actual["ns1/default-token"]).To(Equal([]SecretReference{{Kind: "ServiceAccount", Name: "default", Via: SecretReferenceViaServiceAccount}}))
+	g.Expect(actual["ns1/backup-credentials"]).To(Equal([]SecretReference{{Kind: "CronJob", Name: "backup", Via: SecretReferenceViaEnvFrom}}))
+}
+
+func Test_collectSecretUsages_falls_back_to_v1beta1_cronjobs(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestCronJob("batch/v1beta1", "ns1", "backup", "backup-credentials"))
+	dynamicClient.PrependReactor("list", "cronjobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetResource().Version == "v1" {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "batch", Resource: "cronjobs"}, "")
+		}
+		return false, nil, nil
+	})
+
+	actual, err := collectSecretUsages(context.Background(), fake.NewSimpleClientset(), dynamicClient, "", 0)
+	g.Expect(err).To(BeNil())
+	g.Expect(actual["ns1/backup-credentials"]).To(Equal([]SecretReference{{Kind: "CronJob", Name: "backup", Via: SecretReferenceViaEnvFrom}}))
+}
+
+func newTestCronJob(apiVersion, namespace, name, secret string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       "CronJob",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+		},
+		"spec": map[string]interface{}{
+			"schedule": "@daily",
+			"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":    "backup",
+					"envFrom": []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": secret}}},
+				}},
+			}}}},
+		},
+	}}
+}
+
+func Test_SecretUsages_annotates_and_reports(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	usages := SecretUsages{}
+	usages.add("ns1", "db", "Pod", "web-1", SecretReferenceViaVolume)
+	usages.add("ns1", "db", "Deployment", "web", SecretReferenceViaEnv)
+	usages.add("ns1", "db", "Deployment", "web", SecretReferenceViaVolume)
+	secret := newTestSecret("ns1", "db", nil)
+
+	usages.Annotate(&secret, "used-by")
+	g.Expect(secret.Annotations).To(Equal(map[string]string{"used-by": "Deployment/web,Pod/web-1"}))
+
+	buf := new(bytes.Buffer)
+	g.Expect(writeSecretUsagesTable(usages.Of([]Identifier{"ns1/other", "ns1/db"}), buf)).To(BeNil())
+	g.Expect(buf.String()).To(Equal(`SECRET     USED BY         VIA
+ns1/db     Deployment/web  env
+ns1/db     Deployment/web  volume
+ns1/db     Pod/web-1       volume
+ns1/other  <none>          
+`))
+}
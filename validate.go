@@ -0,0 +1,210 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"golang.org/x/crypto/ssh"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"log"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var ErrInvalidSecrets = errors.New("invalid secrets found")
+
+const (
+	ValidationModeNone = ""
+	ValidationModeWarn = "warn"
+	ValidationModeFail = "fail"
+)
+
+var (
+	bootstrapTokenIdPattern     = regexp.MustCompile(`^[a-z0-9]{6}$`)
+	bootstrapTokenSecretPattern = regexp.MustCompile(`^[a-z0-9]{16}$`)
+)
+
+type Validation struct {
+	Mode string
+}
+
+func (instance *Validation) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("validation.").
+		EnvarNamePrefix("VALIDATION_")
+
+	g.Flag("mode", "If set the content of well-known secret types is validated while exporting. 'warn' logs"+
+		" invalid secrets, 'fail' aborts the export.").
+		Envar("MODE").
+		EnumVar(&instance.Mode, ValidationModeNone, ValidationModeWarn, ValidationModeFail)
+}
+
+func (instance Validation) Check(secret v1.Secret) error {
+	if instance.Mode == ValidationModeNone {
+		return nil
+	}
+	problems := validateSecret(secret)
+	if len(problems) == 0 {
+		return nil
+	}
+	err := fmt.Errorf("secret %v of type %s is invalid: %s", identifierOf(secret), secret.Type, strings.Join(problems, "; "))
+	if instance.Mode == ValidationModeFail {
+		return err
+	}
+	log.Print(err)
+	return nil
+}
+
+type Validate struct {
+	Format ReportFormat
+	Output File
+}
+
+func (instance *Validate) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("validate.").
+		EnvarNamePrefix("VALIDATE_")
+
+	g.Flag("format", fmt.Sprintf("Which format should be used for the results. Can be: %v", AllReportFormats.String())).
+		Default(ReportFormatTable.String()).
+		Envar("FORMAT").
+		SetValue(&instance.Format)
+	g.Flag("output", "Where to write the results to. It can be a regular file, 'stdout' or 'stderr'.").
+		Default(Stdout.String()).
+		Envar("OUTPUT").
+		SetValue(&instance.Output)
+}
+
+type InvalidSecret struct {
+	Secret  Identifier    `json:"secret"`
+	Type    v1.SecretType `json:"type"`
+	Problem string        `json:"problem"`
+}
+
+func (instance Validate) Execute(exporter *KubeSecretsExporter) error {
+	secrets, err := exporter.selectedSecrets(context.Background())
+	if err != nil {
+		return err
+	}
+	invalid := instance.find(secrets)
+	if err := instance.write(exporter.Output.open, invalid); err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		return ErrInvalidSecrets
+	}
+	return nil
+}
+
+func (instance Validate) find(secrets []v1.Secret) []InvalidSecret {
+	result := []InvalidSecret{}
+	for _, secret := range secrets {
+		for _, problem := range validateSecret(secret) {
+			result = append(result, InvalidSecret{
+				Secret:  identifierOf(secret),
+				Type:    secret.Type,
+				Problem: problem,
+			})
+		}
+	}
+	return result
+}
+
+func (instance Validate) write(open outputSink, invalid []InvalidSecret) error {
+	return writeReport(open, instance.Output, instance.Format, func(w io.Writer) error {
+		return writeInvalidSecretsTable(invalid, w)
+	}, invalid)
+}
+
+func writeInvalidSecretsTable(invalid []InvalidSecret, to io.Writer) error {
+	w := tabwriter.NewWriter(to, 0, 4, 2, ' ', 0)
+	if _, err := fmt.Fprintf(w, "SECRET\tTYPE\tPROBLEM\n"); err != nil {
+		return err
+	}
+	for _, entry := range invalid {
+		if _, err := fmt.Fprintf(w, "%v\t%s\t%s\n", entry.Secret, entry.Type, entry.Problem); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func validateSecret(secret v1.Secret) []string {
+	data := secretDataOf(secret)
+	var result []string
+	require := func(keys ...string) bool {
+		ok := true
+		for _, key := range keys {
+			if len(data[key]) == 0 {
+				result = append(result, fmt.Sprintf("%s is missing", key))
+				ok = false
+			}
+		}
+		return ok
+	}
+
+	switch secret.Type {
+	case v1.SecretTypeTLS:
+		if require(v1.TLSCertKey, v1.TLSPrivateKeyKey) {
+			if _, err := tls.X509KeyPair(data[v1.TLSCertKey], data[v1.TLSPrivateKeyKey]); err != nil {
+				result = append(result, fmt.Sprintf("%s and %s are not a valid pair: %v", v1.TLSCertKey, v1.TLSPrivateKeyKey, err))
+			}
+		}
+	case v1.SecretTypeDockerConfigJson:
+		if require(v1.DockerConfigJsonKey) {
+			var config struct {
+				Auths map[string]json.RawMessage `json:"auths"`
+			}
+			if err := json.Unmarshal(data[v1.DockerConfigJsonKey], &config); err != nil {
+				result = append(result, fmt.Sprintf("%s is not valid JSON: %v", v1.DockerConfigJsonKey, err))
+			} else if config.Auths == nil {
+				result = append(result, fmt.Sprintf("%s does not contain auths", v1.DockerConfigJsonKey))
+			}
+		}
+	case v1.SecretTypeDockercfg:
+		if require(v1.DockerConfigKey) {
+			var config map[string]json.RawMessage
+			if err := json.Unmarshal(data[v1.DockerConfigKey], &config); err != nil {
+				result = append(result, fmt.Sprintf("%s is not valid JSON: %v", v1.DockerConfigKey, err))
+			}
+		}
+	case v1.SecretTypeBasicAuth:
+		require(v1.BasicAuthUsernameKey, v1.BasicAuthPasswordKey)
+	case v1.SecretTypeSSHAuth:
+		if require(v1.SSHAuthPrivateKey) {
+			if _, err := ssh.ParseRawPrivateKey(data[v1.SSHAuthPrivateKey]); err != nil {
+				var passphraseMissing *ssh.PassphraseMissingError
+				if !errors.As(err, &passphraseMissing) {
+					result = append(result, fmt.Sprintf("%s is not a valid private key: %v", v1.SSHAuthPrivateKey, err))
+				}
+			}
+		}
+	case v1.SecretTypeBootstrapToken:
+		if require("token-id", "token-secret") {
+			id := string(data["token-id"])
+			if !bootstrapTokenIdPattern.Match(data["token-id"]) {
+				result = append(result, fmt.Sprintf("token-id does not match %v", bootstrapTokenIdPattern))
+			} else if expected := "bootstrap-token-" + id; secret.Name != expected {
+				result = append(result, fmt.Sprintf("name should be %s", expected))
+			}
+			if !bootstrapTokenSecretPattern.Match(data["token-secret"]) {
+				result = append(result, fmt.Sprintf("token-secret does not match %v", bootstrapTokenSecretPattern))
+			}
+		}
+		if expiration, ok := data["expiration"]; ok {
+			if _, err := time.Parse(time.RFC3339, string(expiration)); err != nil {
+				result = append(result, fmt.Sprintf("expiration is not a RFC3339 timestamp: %s", expiration))
+			}
+		}
+		for _, key := range sortedKeysOf(secret) {
+			if value := string(data[key]); strings.HasPrefix(key, "usage-bootstrap-") && value != "true" && value != "false" {
+				result = append(result, fmt.Sprintf("%s should be true or false: %s", key, value))
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func Test_validateSecret(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	certificate, key := newTestKeyPair(g)
+	_, otherKey := newTestKeyPair(g)
+
+	cases := []struct {
+		secret   v1.Secret
+		expected []string
+	}{{
+		secret:   newTestSecretOfType(v1.SecretTypeTLS, "tls", map[string]string{"tls.crt": certificate, "tls.key": key}),
+		expected: nil,
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeTLS, "tls", map[string]string{"tls.crt": certificate, "tls.key": otherKey}),
+		expected: []string{"tls.crt and tls.key are not a valid pair: tls: private key does not match public key"},
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeTLS, "tls", map[string]string{"tls.crt": certificate}),
+		expected: []string{"tls.key is missing"},
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeDockerConfigJson, "docker", map[string]string{".dockerconfigjson": `{"auths":{}}`}),
+		expected: nil,
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeDockerConfigJson, "docker", map[string]string{".dockerconfigjson": `{"foo":{}}`}),
+		expected: []string{".dockerconfigjson does not contain auths"},
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeBasicAuth, "basic", map[string]string{"username": "foo"}),
+		expected: []string{"password is missing"},
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeSSHAuth, "ssh", map[string]string{"ssh-privatekey": key}),
+		expected: nil,
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeSSHAuth, "ssh", map[string]string{"ssh-privatekey": "foo"}),
+		expected: []string{"ssh-privatekey is not a valid private key: ssh: no key found"},
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeBootstrapToken, "bootstrap-token-abcdef", map[string]string{"token-id": "abcdef", "token-secret": "0123456789abcdef", "usage-bootstrap-signing": "true"}),
+		expected: nil,
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeBootstrapToken, "bootstrap-token-abc", map[string]string{"token-id": "abc", "token-secret": "0123456789abcdef", "expiration": "tomorrow"}),
+		expected: []string{"token-id does not match ^[a-z0-9]{6}$", "expiration is not a RFC3339 timestamp: tomorrow"},
+	}, {
+		secret:   newTestSecretOfType(v1.SecretTypeOpaque, "opaque", map[string]string{}),
+		expected: nil,
+	}}
+
+	for _, c := range cases {
+		g.Expect(validateSecret(c.secret)).To(Equal(c.expected), "%s/%s", c.secret.Type, c.secret.Name)
+	}
+}
+
+func Test_Validate_find(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	actual := Validate{}.find([]v1.Secret{
+		newTestSecretOfType(v1.SecretTypeBasicAuth, "a", map[string]string{}),
+		newTestSecretOfType(v1.SecretTypeOpaque, "b", map[string]string{}),
+	})
+
+	buf := new(bytes.Buffer)
+	g.Expect(writeInvalidSecretsTable(actual, buf)).To(BeNil())
+	g.Expect(buf.String()).To(Equal(`SECRET  TYPE                      PROBLEM
+ns1/a   kubernetes.io/basic-auth  username is missing
+ns1/a   kubernetes.io/basic-auth  password is missing
+`))
+}
+
+func Test_Validation_Check(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	secret := newTestSecretOfType(v1.SecretTypeBasicAuth, "a", map[string]string{"username": "foo"})
+
+	g.Expect(Validation{}.Check(secret)).To(BeNil())
+	g.Expect(Validation{Mode: ValidationModeWarn}.Check(secret)).To(BeNil())
+	g.Expect(Validation{Mode: ValidationModeFail}.Check(secret)).To(MatchError("secret ns1/a of type kubernetes.io/basic-auth is invalid: password is missing"))
+}
+
+func newTestSecretOfType(secretType v1.SecretType, name string, data map[string]string) v1.Secret {
+	result := newTestSecret("ns1", name, data)
+	result.Type = secretType
+	return result
+}
+
+func newTestKeyPair(g *WithT) (certificate string, key string) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.Expect(err).To(BeNil())
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificateDer, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	g.Expect(err).To(BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(privateKey)
+	g.Expect(err).To(BeNil())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDer})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
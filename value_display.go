@@ -0,0 +1,83 @@
+package kube_secrets_exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type ValueDisplay uint8
+
+const (
+	ValueDisplayHidden = ValueDisplay(0)
+	ValueDisplayHash   = ValueDisplay(1)
+)
+
+func (instance *ValueDisplay) Set(plain string) error {
+	if v, ok := nameToValueDisplay[strings.ToLower(plain)]; ok {
+		*instance = v
+		return nil
+	}
+	return fmt.Errorf("illegal value display: %s", plain)
+}
+
+func (instance ValueDisplay) String() string {
+	if v, ok := valueDisplayToName[instance]; ok {
+		return v
+	}
+	return fmt.Sprintf("illegal value display: %d", instance)
+}
+
+func (instance ValueDisplay) Display(value []byte) string {
+	switch instance {
+	case ValueDisplayHash:
+		return hashValue(value)
+	default:
+		return ""
+	}
+}
+
+type ValueDisplays []ValueDisplay
+
+func (instance ValueDisplays) String() string {
+	return strings.Join(instance.Strings(), ",")
+}
+
+func (instance ValueDisplays) Strings() []string {
+	strs := make([]string, len(instance))
+	for i, v := range instance {
+		strs[i] = v.String()
+	}
+	return strs
+}
+
+func hashValue(value []byte) string {
+	sum := sha256.Sum256(value)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+var (
+	valueDisplayToName = map[ValueDisplay]string{
+		ValueDisplayHidden: "hidden",
+		ValueDisplayHash:   "hash",
+	}
+
+	nameToValueDisplay = func(in map[ValueDisplay]string) map[string]ValueDisplay {
+		result := make(map[string]ValueDisplay)
+		for f, n := range in {
+			result[n] = f
+		}
+		return result
+	}(valueDisplayToName)
+
+	AllValueDisplays = func(in map[ValueDisplay]string) ValueDisplays {
+		result := make(ValueDisplays, len(in))
+		var i int
+		for f := range in {
+			result[i] = f
+			i++
+		}
+		return result
+	}(valueDisplayToName)
+)
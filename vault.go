@@ -0,0 +1,222 @@
+package kube_secrets_exporter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	"io"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	vaultBinaryBase64 = "base64"
+	vaultBinarySkip   = "skip"
+)
+
+type Vault struct {
+	Address      string
+	Path         Template
+	KvVersion    uint8
+	Token        string
+	RoleId       string
+	SecretId     string
+	AppRoleMount string
+	Namespace    string
+	Binary       string
+	DryRun       bool
+
+	client *http.Client
+	token  string
+}
+
+func (instance *Vault) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("vault.").
+		EnvarNamePrefix("VAULT_")
+
+	g.Flag("path", "Golang template of the Vault API path each secret is written to; example 'secret/data/{{.Namespace}}/{{.Name}}'."+
+		" For KV version 2 the path has to contain the 'data/' segment. Empty means no Vault.").
+		Envar("PATH").
+		SetValue(&instance.Path)
+	g.Flag("address", "Address of Vault. Empty means $VAULT_ADDR.").
+		Envar("ADDRESS").
+		StringVar(&instance.Address)
+	g.Flag("kv-version", "Version of the KV secrets engine. Can be: 1,2").
+		Default("2").
+		Envar("KV_VERSION").
+		Uint8Var(&instance.KvVersion)
+	g.Flag("token", "Token to authenticate with. Empty means $VAULT_TOKEN or AppRole if role-id is set.").
+		Envar("TOKEN").
+		StringVar(&instance.Token)
+	g.Flag("role-id", "Role ID to authenticate with using AppRole.").
+		Envar("ROLE_ID").
+		StringVar(&instance.RoleId)
+	g.Flag("secret-id", "Secret ID to authenticate with using AppRole.").
+		Envar("SECRET_ID").
+		StringVar(&instance.SecretId)
+	g.Flag("approle-mount", "Mount path of the AppRole auth method.").
+		Default("approle").
+		Envar("APPROLE_MOUNT").
+		StringVar(&instance.AppRoleMount)
+	g.Flag("namespace", "Vault Enterprise namespace.").
+		Envar("NAMESPACE").
+		StringVar(&instance.Namespace)
+	g.Flag("binary", "How values which are not valid UTF-8 are written to Vault. Can be: base64,skip").
+		Default(vaultBinaryBase64).
+		Envar("BINARY").
+		EnumVar(&instance.Binary, vaultBinaryBase64, vaultBinarySkip)
+	g.Flag("dry-run", "Only list what would be written to Vault.").
+		Envar("DRY_RUN").
+		BoolVar(&instance.DryRun)
+}
+
+func (instance Vault) IsEnabled() bool {
+	return !instance.Path.IsEmpty()
+}
+
+func (instance *Vault) address() string {
+	if v := instance.Address; v != "" {
+		return strings.TrimSuffix(v, "/")
+	}
+	return strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+}
+
+func (instance *Vault) WriteAll(run OutputRun, secrets []v1.Secret) error {
+	for _, secret := range secrets {
+		if err := instance.Write(run, secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (instance *Vault) Write(run OutputRun, secret v1.Secret) error {
+	if !instance.IsEnabled() {
+		return nil
+	}
+	path, err := instance.Path.Execute(newOutputGroupingContext(&secret, run))
+	if err != nil {
+		return err
+	}
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return fmt.Errorf("vault path of secret %s/%s is empty", secret.Namespace, secret.Name)
+	}
+
+	fields := make(map[string]string)
+	for key, value := range secretDataOf(secret) {
+		if utf8.Valid(value) {
+			fields[key] = string(value)
+		} else if instance.Binary == vaultBinarySkip {
+			log.Printf("skipped binary value of key %s of secret %s/%s for vault", key, secret.Namespace, secret.Name)
+		} else {
+			fields[key] = base64.StdEncoding.EncodeToString(value)
+		}
+	}
+
+	if instance.DryRun {
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		log.Printf("would write %s/%s to vault %s (keys: %s)", secret.Namespace, secret.Name, path, strings.Join(keys, ","))
+		return nil
+	}
+
+	var body interface{} = fields
+	switch instance.KvVersion {
+	case 1:
+	case 2:
+		body = map[string]interface{}{"data": fields}
+	default:
+		return fmt.Errorf("illegal vault kv version: %d", instance.KvVersion)
+	}
+
+	token, err := instance.resolveToken()
+	if err != nil {
+		return err
+	}
+	if err := instance.request(http.MethodPost, path, token, body, nil); err != nil {
+		return fmt.Errorf("cannot write secret %s/%s to vault %s: %w", secret.Namespace, secret.Name, path, err)
+	}
+	return nil
+}
+
+func (instance *Vault) resolveToken() (string, error) {
+	if instance.token != "" {
+		return instance.token, nil
+	}
+	if v := instance.Token; v != "" {
+		instance.token = v
+	} else if instance.RoleId != "" {
+		var resp struct {
+			Auth struct {
+				ClientToken string `json:"client_token"`
+			} `json:"auth"`
+		}
+		if err := instance.request(http.MethodPost, "auth/"+strings.Trim(instance.AppRoleMount, "/")+"/login", "", map[string]string{
+			"role_id":   instance.RoleId,
+			"secret_id": instance.SecretId,
+		}, &resp); err != nil {
+			return "", fmt.Errorf("cannot login to vault using approle: %w", err)
+		}
+		instance.token = resp.Auth.ClientToken
+	} else if v := os.Getenv("VAULT_TOKEN"); v != "" {
+		instance.token = v
+	}
+	if instance.token == "" {
+		return "", fmt.Errorf("there is neither a vault token nor an approle role-id provided")
+	}
+	return instance.token, nil
+}
+
+func (instance *Vault) request(method, path, token string, body interface{}, result interface{}) error {
+	address := instance.address()
+	if address == "" {
+		return fmt.Errorf("there is neither a vault address nor $VAULT_ADDR provided")
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, address+"/v1/"+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v := instance.Namespace; v != "" {
+		req.Header.Set("X-Vault-Namespace", v)
+	}
+
+	client := instance.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("unexpected response %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("cannot parse response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package kube_secrets_exporter
+
+import (
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Vault_Write_with_approle_and_kv2_succeeds(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	written := make(map[string]interface{})
+	var writeToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		g.Expect(json.NewDecoder(r.Body).Decode(&body)).To(BeNil())
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			g.Expect(body).To(Equal(map[string]interface{}{"role_id": "aRole", "secret_id": "aSecret"}))
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"aToken"}}`))
+		default:
+			writeToken = r.Header.Get("X-Vault-Token")
+			written[r.URL.Path] = body
+		}
+	}))
+	defer server.Close()
+	instance := Vault{
+		Address:      server.URL,
+		KvVersion:    2,
+		RoleId:       "aRole",
+		SecretId:     "aSecret",
+		AppRoleMount: "approle",
+	}
+	g.Expect(instance.Path.Set("secret/data/{{.Namespace}}/{{.Name}}")).To(BeNil())
+
+	err := instance.Write(OutputRun{}, newTestSecret("ns1", "a", map[string]string{"user": "foo", "password": "bar"}))
+
+	g.Expect(err).To(BeNil())
+	g.Expect(writeToken).To(Equal("aToken"))
+	g.Expect(written).To(Equal(map[string]interface{}{
+		"/v1/secret/data/ns1/a": map[string]interface{}{
+			"data": map[string]interface{}{"user": "foo", "password": "bar"},
+		},
+	}))
+}
+
+func Test_Vault_Write_with_dryRun_does_not_contact_vault(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	instance := Vault{
+		Address:   server.URL,
+		KvVersion: 1,
+		Token:     "aToken",
+		DryRun:    true,
+	}
+	g.Expect(instance.Path.Set("secret/{{.Namespace}}/{{.Name}}")).To(BeNil())
+
+	err := instance.Write(OutputRun{}, newTestSecret("ns1", "a", map[string]string{"user": "foo"}))
+
+	g.Expect(err).To(BeNil())
+	g.Expect(requests).To(Equal(0))
+}
+
+func Test_Vault_Write_encodes_or_skips_binary_values(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var written []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		g.Expect(json.NewDecoder(r.Body).Decode(&body)).To(BeNil())
+		written = append(written, body)
+	}))
+	defer server.Close()
+	instance := Vault{
+		Address:   server.URL,
+		KvVersion: 1,
+		Token:     "aToken",
+		Binary:    vaultBinaryBase64,
+	}
+	g.Expect(instance.Path.Set("secret/{{.Namespace}}/{{.Name}}")).To(BeNil())
+	secret := newTestSecret("ns1", "a", map[string]string{"user": "foo", "keystore": "\xff\xfe"})
+
+	g.Expect(instance.Write(OutputRun{}, secret)).To(BeNil())
+	instance.Binary = vaultBinarySkip
+	g.Expect(instance.Write(OutputRun{}, secret)).To(BeNil())
+
+	g.Expect(written).To(Equal([]interface{}{
+		map[string]interface{}{"user": "foo", "keystore": "//4="},
+		map[string]interface{}{"user": "foo"},
+	}))
+}
+
+func Test_Vault_Write_uses_run_information_in_path(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer server.Close()
+	instance := Vault{
+		Address:   server.URL,
+		KvVersion: 1,
+		Token:     "aToken",
+	}
+	g.Expect(instance.Path.Set("secret/{{.Cluster}}/{{.Namespace}}/{{.Name}}")).To(BeNil())
+
+	g.Expect(instance.WriteAll(OutputRun{Cluster: "prod"}, []v1.Secret{newTestSecret("ns1", "a", map[string]string{"user": "foo"})})).To(BeNil())
+
+	g.Expect(paths).To(Equal([]string{"/v1/secret/prod/ns1/a"}))
+}
@@ -0,0 +1,306 @@
+package kube_secrets_exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/blaubaer/kingpin"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+type Watch struct {
+	Resync     time.Duration
+	RetryDelay time.Duration
+	Debounce   time.Duration
+}
+
+func (instance *Watch) RegisterFlags(fg kingpin.FlagGroup) {
+	g := fg.FlagGroup("watch.").
+		EnvarNamePrefix("WATCH_")
+
+	g.Flag("resync", "How often all secrets should be listed again to ensure nothing was missed. 0 means never.").
+		Default("10m").
+		Envar("RESYNC").
+		DurationVar(&instance.Resync)
+	g.Flag("retry-delay", "How long to wait before reconnecting after an error.").
+		Default("5s").
+		Envar("RETRY_DELAY").
+		DurationVar(&instance.RetryDelay)
+	g.Flag("debounce", "How long to collect changes before the affected files are rewritten.").
+		Default("1s").
+		Envar("DEBOUNCE").
+		DurationVar(&instance.Debounce)
+}
+
+func (instance Watch) Execute(exporter *KubeSecretsExporter) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	client, err := exporter.Environment.NewClient()
+	if err != nil {
+		return fmt.Errorf("cannot create kubernetes client: %w", err)
+	}
+	run, err := newOutputRun(&exporter.Environment, "")
+	if err != nil {
+		return err
+	}
+
+	w := &secretsWatcher{
+		Watch:     instance,
+		exporter:  exporter,
+		outputRun: run,
+		secrets:   client.CoreV1().Secrets(exporter.Environment.Namespace),
+		entries:   make(map[Identifier]watchedSecret),
+		affected:  make(map[File]bool),
+	}
+	return w.run(ctx)
+}
+
+type watchedSecret struct {
+	secret          *v1.Secret
+	resourceVersion string
+	files           []File
+}
+
+type secretsWatcher struct {
+	Watch
+
+	exporter  *KubeSecretsExporter
+	outputRun OutputRun
+	secrets   corev1.SecretInterface
+	entries   map[Identifier]watchedSecret
+	affected  map[File]bool
+}
+
+func (instance *secretsWatcher) run(ctx context.Context) error {
+	initial := true
+	for {
+		resourceVersion, err := instance.resync(ctx)
+		if err == nil {
+			err = instance.flush()
+		}
+		if err != nil && initial {
+			return err
+		}
+		initial = false
+		if err == nil {
+			err = instance.watch(ctx, resourceVersion)
+		}
+		if ctx.Err() != nil {
+			return instance.flush()
+		}
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("watching secrets failed, retrying in %v: %v", instance.RetryDelay, err)
+			select {
+			case <-ctx.Done():
+				return instance.flush()
+			case <-time.After(instance.RetryDelay):
+			}
+		}
+	}
+}
+
+func (instance *secretsWatcher) resync(ctx context.Context) (string, error) {
+	seen := make(map[Identifier]bool)
+	resourceVersion, err := instance.exporter.visitSecretsOf(ctx, instance.secrets, func(secret v1.Secret, resourceVersion string) error {
+		id := identifierOf(secret)
+		seen[id] = true
+		return instance.update(id, secret, resourceVersion)
+	})
+	if err != nil {
+		return "", err
+	}
+	for id := range instance.entries {
+		if !seen[id] {
+			instance.remove(id)
+		}
+	}
+	return resourceVersion, nil
+}
+
+func (instance *secretsWatcher) watch(ctx context.Context, resourceVersion string) error {
+	var resync <-chan time.Time
+	if instance.Resync > 0 {
+		timer := time.NewTimer(instance.Resync)
+		defer timer.Stop()
+		resync = timer.C
+	}
+	var flush <-chan time.Time
+
+	for {
+		watcher, err := instance.secrets.Watch(ctx, metav1.ListOptions{
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot watch secrets of kubernetes: %w", err)
+		}
+
+	events:
+		for {
+			select {
+			case <-ctx.Done():
+				watcher.Stop()
+				return nil
+			case <-resync:
+				watcher.Stop()
+				return nil
+			case <-flush:
+				flush = nil
+				if err := instance.flush(); err != nil {
+					watcher.Stop()
+					return err
+				}
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					break events
+				}
+				if event.Type == watch.Error {
+					watcher.Stop()
+					return apierrors.FromObject(event.Object)
+				}
+				if m, err := meta.Accessor(event.Object); err == nil && m.GetResourceVersion() != "" {
+					resourceVersion = m.GetResourceVersion()
+				}
+				if err := instance.onEvent(event); err != nil {
+					watcher.Stop()
+					return err
+				}
+				if flush == nil && len(instance.affected) > 0 {
+					flush = time.After(instance.Debounce)
+				}
+			}
+		}
+	}
+}
+
+func (instance *secretsWatcher) onEvent(event watch.Event) error {
+	secret, ok := event.Object.(*v1.Secret)
+	if !ok {
+		return nil
+	}
+	id := identifierOf(*secret)
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		if !instance.exporter.Selector.Matches(*secret) {
+			instance.remove(id)
+			return nil
+		}
+		if err := instance.exporter.onElement(*secret, func(secret v1.Secret, resourceVersion string) error {
+			return instance.update(id, secret, resourceVersion)
+		}); err != nil {
+			return fmt.Errorf("cannot handle secret %v: %w", id, err)
+		}
+	case watch.Deleted:
+		instance.remove(id)
+	}
+	return nil
+}
+
+func (instance *secretsWatcher) update(id Identifier, secret v1.Secret, resourceVersion string) error {
+	existing, exists := instance.entries[id]
+	if exists && resourceVersion != "" && existing.resourceVersion == resourceVersion {
+		return nil
+	}
+	targets, err := instance.exporter.Output.targets(newOutputGroupingContext(&secret, instance.outputRun))
+	if err != nil {
+		return err
+	}
+	if exists {
+		instance.markAffected(existing.files)
+	}
+	files := make([]File, len(targets))
+	for i, target := range targets {
+		files[i] = target.File
+	}
+	instance.markAffected(files)
+	instance.entries[id] = watchedSecret{
+		secret:          &secret,
+		resourceVersion: resourceVersion,
+		files:           files,
+	}
+	return nil
+}
+
+func (instance *secretsWatcher) markAffected(files []File) {
+	for _, f := range files {
+		instance.affected[f] = true
+	}
+}
+
+func (instance *secretsWatcher) isAffected(files []File) bool {
+	for _, f := range files {
+		if instance.affected[f] {
+			return true
+		}
+	}
+	return false
+}
+
+func (instance *secretsWatcher) remove(id Identifier) {
+	if existing, exists := instance.entries[id]; exists {
+		instance.markAffected(existing.files)
+		delete(instance.entries, id)
+	}
+}
+
+func (instance *secretsWatcher) flush() error {
+	if len(instance.affected) == 0 {
+		return nil
+	}
+	consumer := OutputConsumer{
+		Output: instance.exporter.Output,
+		Run:    instance.outputRun,
+	}
+
+	archived := consumer.Archive != ""
+	ids := make([]Identifier, 0, len(instance.entries))
+	for id, entry := range instance.entries {
+		if archived || instance.isAffected(entry.files) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		if _, err := consumer.Consume(instance.entries[id].secret); err != nil {
+			return fmt.Errorf("cannot handle secret %v: %w", id, err)
+		}
+	}
+	for f := range instance.affected {
+		consumer.Ensure(f)
+	}
+
+	if err := consumer.FinalizeWhere(func(f File) bool {
+		return instance.affected[f]
+	}); err != nil {
+		return err
+	}
+	instance.affected = make(map[File]bool)
+	return nil
+}
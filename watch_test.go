@@ -0,0 +1,101 @@
+package kube_secrets_exporter
+
+import (
+	"archive/zip"
+	"context"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_secretsWatcher_rewrites_only_affected_groups(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	a, b := newTestSecret("ns1", "a", map[string]string{"k": "v"}), newTestSecret("ns2", "b", nil)
+	client := fake.NewSimpleClientset(&a, &b)
+	exporter := &KubeSecretsExporter{}
+	g.Expect(exporter.Output.File.Set(filepath.Join(dir, "{{.Namespace}}.yaml"))).To(BeNil())
+
+	instance := &secretsWatcher{
+		exporter: exporter,
+		secrets:  client.CoreV1().Secrets(""),
+		entries:  make(map[Identifier]watchedSecret),
+		affected: make(map[File]bool),
+	}
+
+	_, err := instance.resync(context.Background())
+	g.Expect(err).To(BeNil())
+	g.Expect(instance.flush()).To(BeNil())
+	g.Expect(filepath.Join(dir, "ns1.yaml")).To(BeAnExistingFile())
+	g.Expect(filepath.Join(dir, "ns2.yaml")).To(BeAnExistingFile())
+
+	g.Expect(os.Remove(filepath.Join(dir, "ns2.yaml"))).To(BeNil())
+	g.Expect(instance.onEvent(watch.Event{Type: watch.Deleted, Object: &a})).To(BeNil())
+	g.Expect(instance.flush()).To(BeNil())
+
+	g.Expect(filepath.Join(dir, "ns2.yaml")).NotTo(BeAnExistingFile())
+	content, err := ioutil.ReadFile(filepath.Join(dir, "ns1.yaml"))
+	g.Expect(err).To(BeNil())
+	g.Expect(string(content)).NotTo(ContainSubstring("name: a"))
+}
+
+func Test_secretsWatcher_ignores_unselected_secrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	exporter := &KubeSecretsExporter{}
+	g.Expect(exporter.Selector.Type.Set(string(v1.SecretTypeTLS))).To(BeNil())
+	a := newTestSecret("ns1", "a", nil)
+
+	instance := &secretsWatcher{
+		exporter: exporter,
+		entries:  make(map[Identifier]watchedSecret),
+		affected: make(map[File]bool),
+	}
+
+	g.Expect(instance.onEvent(watch.Event{Type: watch.Added, Object: &a})).To(BeNil())
+	g.Expect(instance.entries).To(BeEmpty())
+	g.Expect(instance.affected).To(BeEmpty())
+}
+
+func Test_secretsWatcher_with_archive_keeps_unchanged_secrets(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir := newTestDirectory(g)
+	defer func() { _ = os.RemoveAll(dir) }()
+	archive := filepath.Join(dir, "out.zip")
+	a, b := newTestSecret("ns1", "a", map[string]string{"k": "v"}), newTestSecret("ns2", "b", nil)
+	client := fake.NewSimpleClientset(&a, &b)
+	exporter := &KubeSecretsExporter{}
+	g.Expect(exporter.Output.File.Set(filepath.Join(dir, "{{.Namespace}}.yaml"))).To(BeNil())
+	g.Expect(exporter.Output.Archive.Set(archive)).To(BeNil())
+
+	instance := &secretsWatcher{
+		exporter: exporter,
+		secrets:  client.CoreV1().Secrets(""),
+		entries:  make(map[Identifier]watchedSecret),
+		affected: make(map[File]bool),
+	}
+
+	_, err := instance.resync(context.Background())
+	g.Expect(err).To(BeNil())
+	g.Expect(instance.flush()).To(BeNil())
+
+	changed := newTestSecret("ns1", "a", map[string]string{"k": "changed"})
+	changed.ResourceVersion = "2"
+	g.Expect(instance.onEvent(watch.Event{Type: watch.Modified, Object: &changed})).To(BeNil())
+	g.Expect(instance.flush()).To(BeNil())
+
+	zr, err := zip.OpenReader(archive)
+	g.Expect(err).To(BeNil())
+	defer func() { _ = zr.Close() }()
+	g.Expect(zr.File).To(HaveLen(2))
+	g.Expect(zr.File[0].Name).To(Equal("ns1.yaml"))
+	g.Expect(zr.File[1].Name).To(Equal("ns2.yaml"))
+}